@@ -22,5 +22,7 @@ set -x
 export VERSION=$(cat version/number)
 
 pushd backup-and-restore-sdk-release
+  # recorded in the blobstore artifacts by the packaging
+  echo "$VERSION" > src/github.com/cloudfoundry-incubator/blobstore-backup-restore/VERSION
   bosh-cli create-release --version $VERSION --tarball=../backup-and-restore-sdk-release-build/backup-and-restore-sdk-$VERSION.tgz --force
 popd
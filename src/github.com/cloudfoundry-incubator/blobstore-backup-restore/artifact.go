@@ -2,8 +2,13 @@ package blobstore
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/cloudfoundry-incubator/sha256sum"
 )
 
 //go:generate counterfeiter -o fakes/fake_artifact.go . Artifact
@@ -12,26 +17,81 @@ type Artifact interface {
 	Load() (map[string]BucketBackup, error)
 }
 
+// ArtifactSchemaVersion is the version of the artifact format written by
+// Save. Load also understands every earlier version, including the original
+// format, which was a bare map of bucket identifiers to backups.
+const ArtifactSchemaVersion = 1
+
+// ToolVersion is the version of the tool that is recorded in the artifacts it
+// writes. It is set to the release version at build time, by the packaging.
+var ToolVersion = "dev"
+
+type artifactEnvelope struct {
+	SchemaVersion int                     `json:"schema_version"`
+	CreatedAt     time.Time               `json:"created_at"`
+	ToolVersion   string                  `json:"tool_version"`
+	BucketCount   int                     `json:"bucket_count"`
+	BlobCounts    map[string]int          `json:"blob_counts"`
+	Buckets       map[string]BucketBackup `json:"buckets"`
+}
+
 type FileArtifact struct {
-	filePath string
+	filePath        string
+	encryptionKey   string
+	requireChecksum bool
 }
 
 func NewFileArtifact(filePath string) FileArtifact {
 	return FileArtifact{filePath: filePath}
 }
 
+// NewEncryptedFileArtifact returns an artifact that is encrypted with the
+// encryption key when saved. Artifacts saved without encryption can still be
+// loaded.
+func NewEncryptedFileArtifact(filePath, encryptionKey string) FileArtifact {
+	return FileArtifact{filePath: filePath, encryptionKey: encryptionKey}
+}
+
+// RequireChecksum returns an artifact that fails to load without a checksum
+// file, instead of loading it without verification as artifacts taken before
+// checksums were introduced are.
+func (a FileArtifact) RequireChecksum() FileArtifact {
+	a.requireChecksum = true
+	return a
+}
+
 func (a FileArtifact) Save(backup map[string]BucketBackup) error {
-	marshalledBackup, err := json.MarshalIndent(backup, "", "  ")
+	blobCounts := map[string]int{}
+	for identifier, bucketBackup := range backup {
+		blobCounts[identifier] = len(bucketBackup.Versions)
+	}
+
+	marshalledBackup, err := json.MarshalIndent(artifactEnvelope{
+		SchemaVersion: ArtifactSchemaVersion,
+		CreatedAt:     time.Now().UTC(),
+		ToolVersion:   ToolVersion,
+		BucketCount:   len(backup),
+		BlobCounts:    blobCounts,
+		Buckets:       backup,
+	}, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(a.filePath, marshalledBackup, 0666)
+	if a.encryptionKey != "" {
+		marshalledBackup, err = encrypt(marshalledBackup, a.encryptionKey)
+		if err != nil {
+			return fmt.Errorf("could not encrypt backup file: %s", err.Error())
+		}
+	}
+
+	// only readable by its owner, as it reveals the names and layout of the buckets
+	err = sha256sum.WriteFileAtomically(a.filePath, marshalledBackup)
 	if err != nil {
 		return fmt.Errorf("could not write backup file: %s", err.Error())
 	}
 
-	return nil
+	return sha256sum.Write(a.filePath)
 }
 
 func (a FileArtifact) Load() (map[string]BucketBackup, error) {
@@ -40,8 +100,63 @@ func (a FileArtifact) Load() (map[string]BucketBackup, error) {
 		return nil, fmt.Errorf("could not read backup file: %s", err.Error())
 	}
 
+	err = sha256sum.Verify(a.filePath, a.requireChecksum)
+	if err != nil {
+		return nil, err
+	}
+
+	if isEncrypted(bytes) {
+		if a.encryptionKey == "" {
+			return nil, errors.New("backup file is encrypted but no encryption key is configured")
+		}
+
+		bytes, err = decrypt(bytes, a.encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	} else if a.encryptionKey != "" {
+		log.Printf("backup file '%s' is not encrypted, loading it as it is\n", a.filePath)
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(bytes, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("backup file has an invalid format: %s", err.Error())
+	}
+
+	if _, isEnvelope := fields["schema_version"]; !isEnvelope {
+		return loadLegacyArtifact(bytes)
+	}
+
+	var envelope artifactEnvelope
+	err = json.Unmarshal(bytes, &envelope)
+	if err != nil {
+		return nil, fmt.Errorf("backup file has an invalid format: %s", err.Error())
+	}
+
+	if envelope.SchemaVersion < 1 || envelope.SchemaVersion > ArtifactSchemaVersion {
+		return nil, fmt.Errorf("backup file has unsupported schema version %d; this version of the tool supports versions up to %d",
+			envelope.SchemaVersion, ArtifactSchemaVersion)
+	}
+
+	if envelope.BucketCount != len(envelope.Buckets) {
+		return nil, fmt.Errorf("backup file is incomplete: expected %d buckets, found %d",
+			envelope.BucketCount, len(envelope.Buckets))
+	}
+
+	for identifier, bucketBackup := range envelope.Buckets {
+		if envelope.BlobCounts[identifier] != len(bucketBackup.Versions) {
+			return nil, fmt.Errorf("backup file is incomplete: expected %d blobs for bucket '%s', found %d",
+				envelope.BlobCounts[identifier], identifier, len(bucketBackup.Versions))
+		}
+	}
+
+	return envelope.Buckets, nil
+}
+
+func loadLegacyArtifact(bytes []byte) (map[string]BucketBackup, error) {
 	var backup map[string]BucketBackup
-	err = json.Unmarshal(bytes, &backup)
+	err := json.Unmarshal(bytes, &backup)
 	if err != nil {
 		return nil, fmt.Errorf("backup file has an invalid format: %s", err.Error())
 	}
@@ -49,13 +164,18 @@ func (a FileArtifact) Load() (map[string]BucketBackup, error) {
 	return backup, nil
 }
 
+// BucketBackup records where a bucket can be restored from. When the blobs were
+// copied into a backup bucket, BucketName is the backup bucket and every blob
+// key is under Prefix.
 type BucketBackup struct {
 	BucketName string          `json:"bucket_name"`
 	RegionName string          `json:"region_name"`
+	Endpoint   string          `json:"endpoint,omitempty"`
+	Prefix     string          `json:"prefix,omitempty"`
 	Versions   []LatestVersion `json:"versions"`
 }
 
 type LatestVersion struct {
 	BlobKey string `json:"blob_key"`
-	Id      string `json:"version_id"`
+	Id      string `json:"version_id,omitempty"`
 }
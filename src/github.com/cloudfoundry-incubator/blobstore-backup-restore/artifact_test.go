@@ -1,7 +1,11 @@
 package blobstore_test
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 
 	. "github.com/cloudfoundry-incubator/blobstore-backup-restore"
 
@@ -33,6 +37,7 @@ var _ = Describe("FileArtifact", func() {
 			"droplets": {
 				BucketName: "my_droplets_bucket",
 				RegionName: "my_droplets_region",
+				Endpoint:   "https://s3.example.com",
 				Versions: []LatestVersion{
 					{BlobKey: "one", Id: "11"},
 					{BlobKey: "two", Id: "21"},
@@ -62,6 +67,272 @@ var _ = Describe("FileArtifact", func() {
 		Expect(savedBackup).To(Equal(backup))
 	})
 
+	It("describes the backup in the artifact", func() {
+		err := fileArtifact.Save(map[string]BucketBackup{
+			"droplets": {
+				BucketName: "my_droplets_bucket",
+				RegionName: "my_droplets_region",
+				Versions: []LatestVersion{
+					{BlobKey: "one", Id: "11"},
+					{BlobKey: "two", Id: "21"},
+				},
+			},
+			"buildpacks": {
+				BucketName: "my_buildpacks_bucket",
+				RegionName: "my_buildpacks_region",
+				Versions:   []LatestVersion{},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := ioutil.ReadFile(artifactPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		var envelope struct {
+			SchemaVersion int            `json:"schema_version"`
+			CreatedAt     time.Time      `json:"created_at"`
+			ToolVersion   string         `json:"tool_version"`
+			BucketCount   int            `json:"bucket_count"`
+			BlobCounts    map[string]int `json:"blob_counts"`
+		}
+		Expect(json.Unmarshal(contents, &envelope)).To(Succeed())
+		Expect(envelope.SchemaVersion).To(Equal(ArtifactSchemaVersion))
+		Expect(envelope.CreatedAt).To(BeTemporally("~", time.Now(), time.Minute))
+		Expect(envelope.ToolVersion).To(Equal(ToolVersion))
+		Expect(envelope.BucketCount).To(Equal(2))
+		Expect(envelope.BlobCounts).To(Equal(map[string]int{"droplets": 2, "buildpacks": 0}))
+	})
+
+	It("saves the artifact so that only its owner can read it", func() {
+		err := fileArtifact.Save(map[string]BucketBackup{})
+		Expect(err).NotTo(HaveOccurred())
+
+		info, err := os.Stat(artifactPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("does not leave temporary files behind", func() {
+		err := fileArtifact.Save(map[string]BucketBackup{})
+		Expect(err).NotTo(HaveOccurred())
+
+		files, err := ioutil.ReadDir(backupDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		fileNames := []string{}
+		for _, file := range files {
+			fileNames = append(fileNames, file.Name())
+		}
+		Expect(fileNames).To(ConsistOf("blobstore.json", "blobstore.json.sha256"))
+	})
+
+	Context("when an artifact already exists", func() {
+		BeforeEach(func() {
+			ioutil.WriteFile(artifactPath, []byte("AN OLD ARTIFACT"), 0666)
+		})
+
+		It("replaces it", func() {
+			err := fileArtifact.Save(map[string]BucketBackup{})
+			Expect(err).NotTo(HaveOccurred())
+
+			contents, err := ioutil.ReadFile(artifactPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).NotTo(Equal("AN OLD ARTIFACT"))
+
+			info, err := os.Stat(artifactPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+	})
+
+	It("saves a checksum of the artifact next to it", func() {
+		err := fileArtifact.Save(map[string]BucketBackup{})
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := ioutil.ReadFile(artifactPath)
+		Expect(err).NotTo(HaveOccurred())
+		checksum, err := ioutil.ReadFile(artifactPath + ".sha256")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(checksum)).To(Equal(fmt.Sprintf("%x  blobstore.json\n", sha256.Sum256(contents))))
+	})
+
+	Context("when the artifact does not match its checksum", func() {
+		BeforeEach(func() {
+			err := fileArtifact.Save(map[string]BucketBackup{})
+			Expect(err).NotTo(HaveOccurred())
+
+			ioutil.WriteFile(artifactPath, []byte("{\"droplets\": {"), 0666)
+		})
+
+		It("returns an error", func() {
+			backup, err := fileArtifact.Load()
+			Expect(backup).To(BeNil())
+			Expect(err).To(MatchError(ContainSubstring("checksum of '" + artifactPath + "' does not match")))
+		})
+	})
+
+	Context("when the artifact is encrypted", func() {
+		var backup map[string]BucketBackup
+
+		BeforeEach(func() {
+			backup = map[string]BucketBackup{
+				"droplets": {
+					BucketName: "my_droplets_bucket",
+					RegionName: "my_droplets_region",
+					Versions:   []LatestVersion{{BlobKey: "one", Id: "11"}},
+				},
+			}
+
+			err := NewEncryptedFileArtifact(artifactPath, "correct horse battery staple").Save(backup)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not save the artifact in plaintext", func() {
+			contents, err := ioutil.ReadFile(artifactPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(contents)).To(HavePrefix("BBRBSENC"))
+			Expect(string(contents)).NotTo(ContainSubstring("my_droplets_bucket"))
+		})
+
+		It("loads it with the encryption key", func() {
+			loadedBackup, err := NewEncryptedFileArtifact(artifactPath, "correct horse battery staple").Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loadedBackup).To(Equal(backup))
+		})
+
+		It("fails to load it with the wrong encryption key", func() {
+			loadedBackup, err := NewEncryptedFileArtifact(artifactPath, "wrong").Load()
+			Expect(loadedBackup).To(BeNil())
+			Expect(err).To(MatchError("could not decrypt backup file: the encryption key is wrong or the file is corrupt"))
+		})
+
+		It("fails to load it without an encryption key", func() {
+			loadedBackup, err := fileArtifact.Load()
+			Expect(loadedBackup).To(BeNil())
+			Expect(err).To(MatchError("backup file is encrypted but no encryption key is configured"))
+		})
+
+		It("fails to load it if it has an unsupported format version", func() {
+			contents, err := ioutil.ReadFile(artifactPath)
+			Expect(err).NotTo(HaveOccurred())
+			contents[8] = 2
+			writeArtifactWithChecksum(artifactPath, contents, 0600)
+
+			loadedBackup, err := NewEncryptedFileArtifact(artifactPath, "correct horse battery staple").Load()
+			Expect(loadedBackup).To(BeNil())
+			Expect(err).To(MatchError("backup file is encrypted with unsupported format version 2"))
+		})
+	})
+
+	Context("when an encryption key is configured but the artifact is not encrypted", func() {
+		BeforeEach(func() {
+			err := fileArtifact.Save(map[string]BucketBackup{})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("loads it", func() {
+			backup, err := NewEncryptedFileArtifact(artifactPath, "correct horse battery staple").Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backup).To(Equal(map[string]BucketBackup{}))
+		})
+	})
+
+	Context("when the artifact has the legacy format", func() {
+		BeforeEach(func() {
+			writeArtifactWithChecksum(artifactPath, []byte(`{
+				"droplets": {
+					"bucket_name": "my_droplets_bucket",
+					"region_name": "my_droplets_region",
+					"versions": [{"blob_key": "one", "version_id": "11"}]
+				}
+			}`), 0666)
+		})
+
+		It("loads it", func() {
+			backup, err := fileArtifact.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backup).To(Equal(map[string]BucketBackup{
+				"droplets": {
+					BucketName: "my_droplets_bucket",
+					RegionName: "my_droplets_region",
+					Versions:   []LatestVersion{{BlobKey: "one", Id: "11"}},
+				},
+			}))
+		})
+	})
+
+	Context("when the artifact has a newer schema version", func() {
+		BeforeEach(func() {
+			writeArtifactWithChecksum(artifactPath, []byte(`{"schema_version": 99, "buckets": {}}`), 0666)
+		})
+
+		It("returns an error", func() {
+			backup, err := fileArtifact.Load()
+			Expect(backup).To(BeNil())
+			Expect(err).To(MatchError(ContainSubstring("backup file has unsupported schema version 99")))
+		})
+	})
+
+	Context("when the artifact is missing buckets", func() {
+		BeforeEach(func() {
+			writeArtifactWithChecksum(artifactPath, []byte(`{
+				"schema_version": 1,
+				"bucket_count": 2,
+				"blob_counts": {"droplets": 0},
+				"buckets": {"droplets": {"bucket_name": "my_droplets_bucket", "versions": []}}
+			}`), 0666)
+		})
+
+		It("returns an error", func() {
+			backup, err := fileArtifact.Load()
+			Expect(backup).To(BeNil())
+			Expect(err).To(MatchError("backup file is incomplete: expected 2 buckets, found 1"))
+		})
+	})
+
+	Context("when the artifact is missing blobs", func() {
+		BeforeEach(func() {
+			writeArtifactWithChecksum(artifactPath, []byte(`{
+				"schema_version": 1,
+				"bucket_count": 1,
+				"blob_counts": {"droplets": 2},
+				"buckets": {"droplets": {"bucket_name": "my_droplets_bucket", "versions": [{"blob_key": "one"}]}}
+			}`), 0666)
+		})
+
+		It("returns an error", func() {
+			backup, err := fileArtifact.Load()
+			Expect(backup).To(BeNil())
+			Expect(err).To(MatchError("backup file is incomplete: expected 2 blobs for bucket 'droplets', found 1"))
+		})
+	})
+
+	Context("when the artifact has no checksum", func() {
+		BeforeEach(func() {
+			ioutil.WriteFile(artifactPath, []byte(`{"droplets": {"bucket_name": "my_droplets_bucket"}}`), 0666)
+		})
+
+		It("loads it without verifying it", func() {
+			backup, err := fileArtifact.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(backup).To(Equal(map[string]BucketBackup{
+				"droplets": {BucketName: "my_droplets_bucket"},
+			}))
+		})
+
+		Context("and checksums are required", func() {
+			BeforeEach(func() {
+				fileArtifact = fileArtifact.RequireChecksum()
+			})
+
+			It("returns an error", func() {
+				backup, err := fileArtifact.Load()
+				Expect(backup).To(BeNil())
+				Expect(err).To(MatchError("no checksum file found for '" + artifactPath + "'"))
+			})
+		})
+	})
+
 	Context("when saving the file fails", func() {
 		BeforeEach(func() {
 			fileArtifact = NewFileArtifact("/this/path/does/not/exist")
@@ -87,7 +358,7 @@ var _ = Describe("FileArtifact", func() {
 
 	Context("when the artifact has an invalid format", func() {
 		BeforeEach(func() {
-			ioutil.WriteFile(artifactPath, []byte("THIS IS NOT VALID JSON"), 0666)
+			writeArtifactWithChecksum(artifactPath, []byte("THIS IS NOT VALID JSON"), 0666)
 		})
 
 		It("returns an error", func() {
@@ -97,3 +368,9 @@ var _ = Describe("FileArtifact", func() {
 		})
 	})
 })
+
+func writeArtifactWithChecksum(artifactPath string, contents []byte, perm os.FileMode) {
+	Expect(ioutil.WriteFile(artifactPath, contents, perm)).To(Succeed())
+	checksum := fmt.Sprintf("%x  %s\n", sha256.Sum256(contents), filepath.Base(artifactPath))
+	Expect(ioutil.WriteFile(artifactPath+".sha256", []byte(checksum), perm)).To(Succeed())
+}
@@ -1,21 +1,34 @@
 package blobstore
 
-import "fmt"
+import "time"
+
+const backupPrefixTimeFormat = "2006-01-02T15-04-05Z"
 
 type Backuper struct {
-	buckets  map[string]Bucket
-	artifact Artifact
+	buckets       map[string]Bucket
+	backupBuckets map[string]Bucket
+	artifact      Artifact
 }
 
-func NewBackuper(buckets map[string]Bucket, artifact Artifact) Backuper {
+// backupBuckets holds, for the identifiers that have one, the bucket the live
+// blobs are copied into. The other buckets are backed up by recording their
+// latest version ids.
+func NewBackuper(buckets, backupBuckets map[string]Bucket, artifact Artifact) Backuper {
 	return Backuper{
-		buckets:  buckets,
-		artifact: artifact,
+		buckets:       buckets,
+		backupBuckets: backupBuckets,
+		artifact:      artifact,
 	}
 }
 
 func (b Backuper) Backup() error {
+	err := NewVersioningValidator(b.VersionedBuckets()).Validate()
+	if err != nil {
+		return err
+	}
+
 	backup := map[string]BucketBackup{}
+	timestamp := time.Now().UTC().Format(backupPrefixTimeFormat)
 
 	for identifier, bucket := range b.buckets {
 		versions, err := bucket.Versions()
@@ -24,13 +37,24 @@ func (b Backuper) Backup() error {
 		}
 
 		latestVersions := filterLatest(versions)
-		if containsNullVersion(latestVersions) {
-			return fmt.Errorf("failed to retrieve versions; bucket '%s' has `null` VerionIds", bucket.Name())
+
+		backupBucket, isCopying := b.backupBuckets[identifier]
+		if isCopying {
+			backup[identifier], err = copyToBackupBucket(bucket, backupBucket, timestamp+"/"+identifier+"/", latestVersions)
+			if err != nil {
+				return err
+			}
+			continue
 		}
 
+		// blobs written before versioning was enabled have a `null` version id.
+		// It stays valid, and can be copied from, for as long as versioning is
+		// enabled, which the validator checks before every backup and the
+		// bucket checks again before restoring it.
 		backup[identifier] = BucketBackup{
 			BucketName: bucket.Name(),
 			RegionName: bucket.RegionName(),
+			Endpoint:   bucket.Endpoint(),
 			Versions:   latestVersions,
 		}
 	}
@@ -38,19 +62,42 @@ func (b Backuper) Backup() error {
 	return b.artifact.Save(backup)
 }
 
-func containsNullVersion(latestVersions []LatestVersion) bool {
-	for _, version := range latestVersions {
-		if version.Id == "null" {
-			return true
+// VersionedBuckets are the buckets that are backed up by recording their
+// version ids, and so must have versioning enabled.
+func (b Backuper) VersionedBuckets() map[string]Bucket {
+	versionedBuckets := map[string]Bucket{}
+	for identifier, bucket := range b.buckets {
+		if _, isCopying := b.backupBuckets[identifier]; !isCopying {
+			versionedBuckets[identifier] = bucket
 		}
 	}
-	return false
+	return versionedBuckets
+}
+
+func copyToBackupBucket(bucket, backupBucket Bucket, prefix string, latestVersions []LatestVersion) (BucketBackup, error) {
+	err := backupBucket.CopyVersionsToPrefix(bucket.RegionName(), bucket.Name(), prefix, latestVersions)
+	if err != nil {
+		return BucketBackup{}, err
+	}
+
+	copiedFiles := []LatestVersion{}
+	for _, version := range latestVersions {
+		copiedFiles = append(copiedFiles, LatestVersion{BlobKey: version.BlobKey})
+	}
+
+	return BucketBackup{
+		BucketName: backupBucket.Name(),
+		RegionName: backupBucket.RegionName(),
+		Endpoint:   backupBucket.Endpoint(),
+		Prefix:     prefix,
+		Versions:   copiedFiles,
+	}, nil
 }
 
 func filterLatest(versions []Version) []LatestVersion {
 	filteredVersions := []LatestVersion{}
 	for _, version := range versions {
-		if version.IsLatest {
+		if version.IsLatest && !version.IsDeleteMarker {
 			filteredVersions = append(filteredVersions, LatestVersion{Id: version.Id, BlobKey: version.Key})
 		}
 	}
@@ -32,7 +32,7 @@ var _ = Describe("Backuper", func() {
 			"droplets":   dropletsBucket,
 			"buildpacks": buildpacksBucket,
 			"packages":   packagesBucket,
-		}, artifact)
+		}, map[string]Bucket{}, artifact)
 	})
 
 	JustBeforeEach(func() {
@@ -43,6 +43,7 @@ var _ = Describe("Backuper", func() {
 		BeforeEach(func() {
 			dropletsBucket.NameReturns("my_droplets_bucket")
 			dropletsBucket.RegionNameReturns("my_droplets_region")
+			dropletsBucket.EndpointReturns("https://s3.example.com")
 			dropletsBucket.VersionsReturns([]Version{
 				{Key: "one", Id: "11", IsLatest: false},
 				{Key: "one", Id: "12", IsLatest: false},
@@ -72,6 +73,7 @@ var _ = Describe("Backuper", func() {
 				"droplets": {
 					BucketName: "my_droplets_bucket",
 					RegionName: "my_droplets_region",
+					Endpoint:   "https://s3.example.com",
 					Versions: []LatestVersion{
 						{BlobKey: "one", Id: "13"},
 						{BlobKey: "two", Id: "22"},
@@ -95,6 +97,42 @@ var _ = Describe("Backuper", func() {
 		})
 	})
 
+	Context("when the latest version of a file is a delete marker", func() {
+		BeforeEach(func() {
+			dropletsBucket.NameReturns("my_droplets_bucket")
+			dropletsBucket.VersionsReturns([]Version{
+				{Key: "one", Id: "11", IsLatest: true},
+				{Key: "two", Id: "21", IsLatest: false},
+				{Key: "two", Id: "22", IsLatest: true, IsDeleteMarker: true},
+			}, nil)
+		})
+
+		It("does not back the file up", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(artifact.SaveArgsForCall(0)).To(HaveKeyWithValue("droplets", BucketBackup{
+				BucketName: "my_droplets_bucket",
+				Versions: []LatestVersion{
+					{BlobKey: "one", Id: "11"},
+				},
+			}))
+		})
+	})
+
+	Context("when a bucket does not have versioning enabled", func() {
+		BeforeEach(func() {
+			buildpacksBucket.CheckVersioningReturns(errors.New("versioning is suspended on bucket 'my_buildpacks_bucket'"))
+		})
+
+		It("fails before backing up any bucket", func() {
+			Expect(err).To(MatchError(ContainSubstring("versioning is suspended on bucket 'my_buildpacks_bucket'")))
+
+			Expect(dropletsBucket.VersionsCallCount()).To(Equal(0))
+			Expect(buildpacksBucket.VersionsCallCount()).To(Equal(0))
+			Expect(packagesBucket.VersionsCallCount()).To(Equal(0))
+			Expect(artifact.SaveCallCount()).To(Equal(0))
+		})
+	})
+
 	Context("when retrieving the versions from the buckets fails", func() {
 		BeforeEach(func() {
 			dropletsBucket.VersionsReturns([]Version{}, nil)
@@ -124,8 +162,15 @@ var _ = Describe("Backuper", func() {
 				packagesBucket.NameReturns("my_packages_bucket")
 			})
 
-			It("returns the error", func() {
-				Expect(err).To(MatchError("failed to retrieve versions; bucket 'my_packages_bucket' has `null` VerionIds"))
+			It("records the `null` version like any other", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(artifact.SaveArgsForCall(0)).To(HaveKeyWithValue("packages", BucketBackup{
+					BucketName: "my_packages_bucket",
+					Versions: []LatestVersion{
+						{BlobKey: "one", Id: "11"},
+						{BlobKey: "two", Id: "null"},
+					},
+				}))
 			})
 		})
 
@@ -146,6 +191,99 @@ var _ = Describe("Backuper", func() {
 		})
 	})
 
+	Context("when a bucket has a backup bucket", func() {
+		var backupBucket *fakes.FakeBucket
+
+		BeforeEach(func() {
+			backupBucket = new(fakes.FakeBucket)
+			backupBucket.NameReturns("my_backup_bucket")
+			backupBucket.RegionNameReturns("my_backup_region")
+			backupBucket.EndpointReturns("https://backup.example.com")
+
+			dropletsBucket.NameReturns("my_droplets_bucket")
+			dropletsBucket.RegionNameReturns("my_droplets_region")
+			dropletsBucket.VersionsReturns([]Version{
+				{Key: "one", Id: "11", IsLatest: false},
+				{Key: "one", Id: "12", IsLatest: true},
+				{Key: "two", Id: "null", IsLatest: true},
+			}, nil)
+
+			backuper = NewBackuper(map[string]Bucket{
+				"droplets": dropletsBucket,
+			}, map[string]Bucket{
+				"droplets": backupBucket,
+			}, artifact)
+		})
+
+		It("copies the latest versions into the backup bucket under a timestamped prefix", func() {
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(backupBucket.CopyVersionsToPrefixCallCount()).To(Equal(1))
+			regionName, bucketName, prefix, versions := backupBucket.CopyVersionsToPrefixArgsForCall(0)
+			Expect(regionName).To(Equal("my_droplets_region"))
+			Expect(bucketName).To(Equal("my_droplets_bucket"))
+			Expect(prefix).To(MatchRegexp(`^\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}Z/droplets/$`))
+			Expect(versions).To(Equal([]LatestVersion{
+				{BlobKey: "one", Id: "12"},
+				{BlobKey: "two", Id: "null"},
+			}))
+		})
+
+		It("does not require the bucket to have versioning enabled", func() {
+			Expect(dropletsBucket.CheckVersioningCallCount()).To(Equal(0))
+		})
+
+		It("stores the location of the copies in the artifact", func() {
+			_, _, prefix, _ := backupBucket.CopyVersionsToPrefixArgsForCall(0)
+
+			Expect(artifact.SaveArgsForCall(0)).To(Equal(map[string]BucketBackup{
+				"droplets": {
+					BucketName: "my_backup_bucket",
+					RegionName: "my_backup_region",
+					Endpoint:   "https://backup.example.com",
+					Prefix:     prefix,
+					Versions: []LatestVersion{
+						{BlobKey: "one"},
+						{BlobKey: "two"},
+					},
+				},
+			}))
+		})
+
+		Context("and the bucket has never had versioning enabled", func() {
+			BeforeEach(func() {
+				dropletsBucket.CheckVersioningReturns(errors.New("versioning is not enabled on bucket 'my_droplets_bucket'"))
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "null", IsLatest: true},
+					{Key: "two", Id: "null", IsLatest: true},
+				}, nil)
+			})
+
+			It("copies the `null` versions into the backup bucket", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(backupBucket.CopyVersionsToPrefixCallCount()).To(Equal(1))
+				_, _, _, versions := backupBucket.CopyVersionsToPrefixArgsForCall(0)
+				Expect(versions).To(Equal([]LatestVersion{
+					{BlobKey: "one", Id: "null"},
+					{BlobKey: "two", Id: "null"},
+				}))
+				Expect(artifact.SaveCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("and copying into the backup bucket fails", func() {
+			BeforeEach(func() {
+				backupBucket.CopyVersionsToPrefixReturns(errors.New("failed to copy versions to bucket 'my_backup_bucket'"))
+			})
+
+			It("returns the error without saving the artifact", func() {
+				Expect(err).To(MatchError("failed to copy versions to bucket 'my_backup_bucket'"))
+				Expect(artifact.SaveCallCount()).To(Equal(0))
+			})
+		})
+	})
+
 	Context("when storing the versions in the artifact fails", func() {
 		BeforeEach(func() {
 			dropletsBucket.VersionsReturns([]Version{}, nil)
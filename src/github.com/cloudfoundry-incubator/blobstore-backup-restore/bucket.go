@@ -1,40 +1,74 @@
 package blobstore
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os/exec"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
+var errTruncatedListing = errors.New("listing was truncated but no next marker was returned")
+
+const maxKeysPerDelete = 1000
+
 //go:generate counterfeiter -o fakes/fake_bucket.go . Bucket
 type Bucket interface {
 	Name() string
 	RegionName() string
+	Endpoint() string
 	Versions() ([]Version, error)
-	PutVersions(regionName, bucketName string, versions []LatestVersion) error
+	CheckVersioning() error
+	CopyVersions(regionName, bucketName string, versions []LatestVersion) error
+	CopyVersionsFromPrefix(regionName, bucketName, prefix string, versions []LatestVersion) error
+	CopyVersionsToPrefix(regionName, bucketName, prefix string, versions []LatestVersion) error
+	DeleteFiles(keys []string) error
 }
 
 type S3Bucket struct {
-	awsCliPath string
-	name       string
-	regionName string
-	accessKey  S3AccessKey
+	name              string
+	regionName        string
+	endpoint          S3Endpoint
+	credentials       S3Credentials
+	sourceCredentials S3Credentials
+	workers           int
+	client            *s3.S3
 }
 
-type S3AccessKey struct {
-	Id     string
-	Secret string
+// S3Endpoint describes an S3-compatible API to use instead of AWS. The zero
+// value means AWS itself.
+type S3Endpoint struct {
+	URL          string
+	UsePathStyle bool
+	CACert       string
 }
 
-func NewS3Bucket(awsCliPath, name, region string, accessKey S3AccessKey) S3Bucket {
-	return S3Bucket{
-		awsCliPath: awsCliPath,
-		name:       name,
-		regionName: region,
-		accessKey:  accessKey,
+// sourceCredentials are only needed when restoring from a bucket in another account
+func NewS3Bucket(name, region string, endpoint S3Endpoint, s3Credentials, sourceCredentials S3Credentials, workers int) (S3Bucket, error) {
+	client, err := newS3Client(region, endpoint, s3Credentials)
+	if err != nil {
+		return S3Bucket{}, err
 	}
+
+	if workers < 1 {
+		workers = defaultWorkers
+	}
+
+	return S3Bucket{
+		name:              name,
+		regionName:        region,
+		endpoint:          endpoint,
+		credentials:       s3Credentials,
+		sourceCredentials: sourceCredentials,
+		workers:           workers,
+		client:            client,
+	}, nil
 }
 
 func (b S3Bucket) Name() string {
@@ -45,133 +79,319 @@ func (b S3Bucket) RegionName() string {
 	return b.regionName
 }
 
+// Endpoint is the URL of the S3-compatible API the bucket is on, or empty for
+// AWS.
+func (b S3Bucket) Endpoint() string {
+	return b.endpoint.URL
+}
+
 func (b S3Bucket) Versions() ([]Version, error) {
-	output, err := b.runS3ApiCommand("list-object-versions", "--bucket", b.name)
+	versions := []Version{}
+	var pageErr error
+
+	err := b.client.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(b.name),
+	}, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, version := range page.Versions {
+			versions = append(versions, Version{
+				Key:          aws.StringValue(version.Key),
+				Id:           aws.StringValue(version.VersionId),
+				IsLatest:     aws.BoolValue(version.IsLatest),
+				LastModified: aws.TimeValue(version.LastModified),
+			})
+		}
+		for _, deleteMarker := range page.DeleteMarkers {
+			versions = append(versions, Version{
+				Key:            aws.StringValue(deleteMarker.Key),
+				Id:             aws.StringValue(deleteMarker.VersionId),
+				IsLatest:       aws.BoolValue(deleteMarker.IsLatest),
+				IsDeleteMarker: true,
+				LastModified:   aws.TimeValue(deleteMarker.LastModified),
+			})
+		}
+
+		if aws.BoolValue(page.IsTruncated) && aws.StringValue(page.NextKeyMarker) == "" {
+			pageErr = errTruncatedListing
+			return false
+		}
+
+		return true
+	})
+	if err == nil {
+		err = pageErr
+	}
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list versions of bucket '%s': %s", b.name, err.Error())
 	}
 
-	response := S3ListVersionsResponse{}
-	err = json.Unmarshal(output, &response)
+	return versions, nil
+}
+
+func (b S3Bucket) CheckVersioning() error {
+	return checkVersioning(b.client, b.name)
+}
+
+func checkVersioning(client *s3.S3, bucketName string) error {
+	output, err := client.GetBucketVersioning(&s3.GetBucketVersioningInput{
+		Bucket: aws.String(bucketName),
+	})
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to get the versioning status of bucket '%s': %s", bucketName, err.Error())
 	}
 
-	return response.Versions, nil
-}
+	switch aws.StringValue(output.Status) {
+	case s3.BucketVersioningStatusEnabled:
+		return nil
+	case s3.BucketVersioningStatusSuspended:
+		return fmt.Errorf("versioning is suspended on bucket '%s'", bucketName)
+	}
 
-func (b S3Bucket) PutVersions(regionName, bucketName string, versions []LatestVersion) error {
-	var err error
+	return fmt.Errorf("versioning has never been enabled on bucket '%s'", bucketName)
+}
 
-	for _, version := range versions {
-		err = b.putVersion(regionName, bucketName, version)
+func (b S3Bucket) CopyVersions(regionName, bucketName string, versions []LatestVersion) error {
+	// a `null` version id names whichever blob was last written while
+	// versioning was off, so it only still names the backed up blob if
+	// versioning has stayed enabled since the backup
+	if hasNullVersion(versions) {
+		sourceClient, err := b.newSourceClient(regionName)
 		if err != nil {
 			return err
 		}
+
+		err = checkVersioning(sourceClient, bucketName)
+		if err != nil {
+			return fmt.Errorf("cannot copy the `null` versions from bucket '%s': %s", bucketName, err.Error())
+		}
 	}
 
-	files, err := b.listFiles()
-	if err != nil {
-		return err
+	return b.copyVersions(regionName, bucketName, "", "", versions)
+}
+
+// CopyVersionsFromPrefix copies each version from under prefix in the other
+// bucket to its key without the prefix in this bucket.
+func (b S3Bucket) CopyVersionsFromPrefix(regionName, bucketName, prefix string, versions []LatestVersion) error {
+	return b.copyVersions(regionName, bucketName, prefix, "", versions)
+}
+
+// CopyVersionsToPrefix copies each version from the other bucket to its key
+// under prefix in this bucket.
+func (b S3Bucket) CopyVersionsToPrefix(regionName, bucketName, prefix string, versions []LatestVersion) error {
+	return b.copyVersions(regionName, bucketName, "", prefix, versions)
+}
+
+func (b S3Bucket) copyVersions(regionName, bucketName, sourcePrefix, destinationPrefix string, versions []LatestVersion) error {
+	copyVersion := func(version LatestVersion) error {
+		return b.copyVersion(bucketName, sourcePrefix, destinationPrefix, version)
 	}
 
-	for _, file := range files {
-		included := versionsIncludeFile(file, versions)
-		if !included {
-			err = b.deleteFile(file)
-			if err != nil {
-				return err
-			}
+	if b.isTransfer() {
+		sourceClient, err := b.newSourceClient(regionName)
+		if err != nil {
+			return err
 		}
+
+		uploader := s3manager.NewUploaderWithClient(b.client)
+		copyVersion = func(version LatestVersion) error {
+			return b.transferVersion(sourceClient, uploader, bucketName, sourcePrefix, destinationPrefix, version)
+		}
+	}
+
+	errs := executeInParallel(b.workers, len(versions), func(index int) error {
+		return copyVersion(versions[index])
+	})
+	if len(errs) != 0 {
+		return formatErrors(fmt.Sprintf("failed to copy versions to bucket '%s'", b.name), errs)
 	}
 
 	return nil
 }
 
-func (b S3Bucket) putVersion(regionName, bucketName string, version LatestVersion) error {
-	_, err := b.runS3ApiCommand(
-		"copy-object",
-		"--bucket", b.name,
-		"--key", version.BlobKey,
-		"--copy-source", fmt.Sprintf("/%s/%s?versionId=%s", bucketName, version.BlobKey, version.Id),
-	)
+// isTransfer is true when the other bucket is read with other credentials, so
+// versions have to be streamed rather than copied by S3.
+func (b S3Bucket) isTransfer() bool {
+	return b.sourceCredentials != (S3Credentials{})
+}
 
-	return err
+func (b S3Bucket) newSourceClient(regionName string) (*s3.S3, error) {
+	if b.isTransfer() {
+		return newS3Client(regionName, b.endpoint, b.sourceCredentials)
+	}
+	return newS3Client(regionName, b.endpoint, b.credentials)
 }
 
-func (b S3Bucket) listFiles() ([]string, error) {
-	output, err := b.runS3ApiCommand("list-objects", "--bucket", b.name)
-	if err != nil {
-		return nil, err
+func (b S3Bucket) DeleteFiles(keys []string) error {
+	batches := splitIntoBatches(keys, maxKeysPerDelete)
+	errs := executeInParallel(b.workers, len(batches), func(index int) error {
+		return b.deleteFiles(batches[index])
+	})
+	if len(errs) != 0 {
+		return formatErrors(fmt.Sprintf("failed to delete files from bucket '%s'", b.name), errs)
 	}
 
-	response := S3ListResponse{}
-	err = json.Unmarshal(output, &response)
+	return nil
+}
+
+func (b S3Bucket) copyVersion(bucketName, sourcePrefix, destinationPrefix string, version LatestVersion) error {
+	_, err := b.client.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(b.name),
+		Key:        aws.String(destinationPrefix + version.BlobKey),
+		CopySource: aws.String(copySource(bucketName, sourcePrefix+version.BlobKey, version.Id)),
+	})
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to copy %s: %s", describeVersion(version), err.Error())
 	}
 
-	files := []string{}
-	for _, object := range response.Contents {
-		files = append(files, object.Key)
+	return nil
+}
+
+// transferVersion streams a version through this process, so that reading it
+// and writing it can be authorised by different accounts. The headers and user
+// metadata are copied along with the contents, as CopyObject would.
+func (b S3Bucket) transferVersion(sourceClient *s3.S3, uploader *s3manager.Uploader, bucketName, sourcePrefix, destinationPrefix string, version LatestVersion) error {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(sourcePrefix + version.BlobKey),
+	}
+	if version.Id != "" {
+		input.VersionId = aws.String(version.Id)
 	}
 
-	return files, nil
-}
+	// Without an explicit Accept-Encoding, net/http transparently decompresses
+	// gzip-encoded blobs, which would then be written with the wrong contents
+	request, output := sourceClient.GetObjectRequest(input)
+	request.HTTPRequest.Header.Set("Accept-Encoding", "identity")
+	err := request.Send()
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %s", describeVersion(version), err.Error())
+	}
+	defer output.Body.Close()
+
+	_, err = uploader.Upload(&s3manager.UploadInput{
+		Bucket:             aws.String(b.name),
+		Key:                aws.String(destinationPrefix + version.BlobKey),
+		Body:               output.Body,
+		ContentType:        output.ContentType,
+		ContentEncoding:    output.ContentEncoding,
+		ContentDisposition: output.ContentDisposition,
+		ContentLanguage:    output.ContentLanguage,
+		CacheControl:       output.CacheControl,
+		Metadata:           output.Metadata,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %s", describeVersion(version), err.Error())
+	}
 
-func (b S3Bucket) deleteFile(key string) error {
-	_, err := b.runS3ApiCommand(
-		"delete-object",
-		"--bucket", b.name,
-		"--key", key,
-	)
+	return nil
+}
 
-	return err
+func hasNullVersion(versions []LatestVersion) bool {
+	for _, version := range versions {
+		if version.Id == "null" {
+			return true
+		}
+	}
+	return false
 }
 
-func (b S3Bucket) runS3ApiCommand(args ...string) ([]byte, error) {
-	outputBuffer := new(bytes.Buffer)
-	errorBuffer := new(bytes.Buffer)
+func describeVersion(version LatestVersion) string {
+	if version.Id == "" {
+		return fmt.Sprintf("'%s'", version.BlobKey)
+	}
+
+	return fmt.Sprintf("version '%s' of '%s'", version.Id, version.BlobKey)
+}
 
-	baseArgs := []string{"--output", "json", "--region", b.regionName, "s3api"}
-	awsCmd := exec.Command(b.awsCliPath, append(baseArgs, args...)...)
-	awsCmd.Env = append(awsCmd.Env, "AWS_ACCESS_KEY_ID="+b.accessKey.Id)
-	awsCmd.Env = append(awsCmd.Env, "AWS_SECRET_ACCESS_KEY="+b.accessKey.Secret)
-	awsCmd.Stdout = outputBuffer
-	awsCmd.Stderr = errorBuffer
+func (b S3Bucket) deleteFiles(keys []string) error {
+	objects := []*s3.ObjectIdentifier{}
+	for _, key := range keys {
+		objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+	}
 
-	err := awsCmd.Run()
+	output, err := b.client.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(b.name),
+		Delete: &s3.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
 	if err != nil {
-		return nil, errors.New(errorBuffer.String())
+		return fmt.Errorf("failed to delete %d file(s) starting at '%s': %s", len(keys), keys[0], err.Error())
 	}
 
-	return outputBuffer.Bytes(), nil
-}
+	if len(output.Errors) != 0 {
+		errs := []error{}
+		for _, deleteError := range output.Errors {
+			errs = append(errs, fmt.Errorf("failed to delete '%s': %s: %s",
+				aws.StringValue(deleteError.Key),
+				aws.StringValue(deleteError.Code),
+				aws.StringValue(deleteError.Message),
+			))
+		}
+		return formatErrors(fmt.Sprintf("failed to delete %d of %d file(s)", len(errs), len(keys)), errs)
+	}
 
-type S3ListVersionsResponse struct {
-	Versions []Version
+	return nil
 }
 
-type Version struct {
-	Key      string
-	Id       string `json:"VersionId"`
-	IsLatest bool
-}
+func splitIntoBatches(keys []string, batchSize int) [][]string {
+	batches := [][]string{}
+	for len(keys) > batchSize {
+		batches = append(batches, keys[:batchSize])
+		keys = keys[batchSize:]
+	}
+
+	if len(keys) != 0 {
+		batches = append(batches, keys)
+	}
 
-type S3ListResponse struct {
-	Contents []Object
+	return batches
 }
 
-type Object struct {
-	Key string
+func newS3Client(regionName string, endpoint S3Endpoint, s3Credentials S3Credentials) (*s3.S3, error) {
+	awsCredentials, err := newCredentials(regionName, s3Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	options := session.Options{
+		Config: aws.Config{
+			Region:           aws.String(regionName),
+			Credentials:      awsCredentials,
+			S3ForcePathStyle: aws.Bool(endpoint.UsePathStyle),
+		},
+	}
+
+	if endpoint.URL != "" {
+		options.Config.Endpoint = aws.String(endpoint.URL)
+	}
+
+	if endpoint.CACert != "" {
+		// the SDK installs the CA on the session's client, which would otherwise be http.DefaultClient
+		options.Config.HTTPClient = &http.Client{}
+		options.CustomCABundle = strings.NewReader(endpoint.CACert)
+	}
+
+	awsSession, err := session.NewSessionWithOptions(options)
+	if err != nil {
+		return nil, fmt.Errorf("could not create an S3 session: %s", err.Error())
+	}
+
+	return s3.New(awsSession), nil
 }
 
-func versionsIncludeFile(file string, versions []LatestVersion) bool {
-	for _, version := range versions {
-		if version.BlobKey == file {
-			return true
-		}
+func copySource(bucketName, key, versionId string) string {
+	if versionId == "" {
+		return fmt.Sprintf("%s/%s", bucketName, url.PathEscape(key))
 	}
 
-	return false
+	return fmt.Sprintf("%s/%s?versionId=%s", bucketName, url.PathEscape(key), url.QueryEscape(versionId))
+}
+
+type Version struct {
+	Key            string
+	Id             string
+	IsLatest       bool
+	IsDeleteMarker bool
+	LastModified   time.Time
 }
@@ -5,17 +5,24 @@ import (
 
 	"os"
 
-	"os/exec"
-
-	"bytes"
-	"encoding/json"
-
-	"io/ioutil"
-
+	"encoding/pem"
+	"encoding/xml"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
 )
 
 var _ = Describe("S3Bucket", func() {
@@ -24,19 +31,20 @@ var _ = Describe("S3Bucket", func() {
 
 	var region = "eu-west-1"
 	var bucketName = "bbr-integration-test-bucket"
+	var endpoint = S3Endpoint{URL: os.Getenv("S3_ENDPOINT"), UsePathStyle: os.Getenv("S3_ENDPOINT") != ""}
 
 	var firstVersionOfTest1 string
 	var secondVersionOfTest1 string
 	var thirdVersionOfTest1 string
 	var firstVersionOfTest2 string
-	var deletedVersionOfTest2 string
+	var deleteMarkerOfTest2 string
 
 	BeforeEach(func() {
 		firstVersionOfTest1 = uploadFile(region, bucketName, "test-1", "TEST-1-A")
 		secondVersionOfTest1 = uploadFile(region, bucketName, "test-1", "TEST-1-B")
 		thirdVersionOfTest1 = uploadFile(region, bucketName, "test-1", "TEST-1-C")
 		firstVersionOfTest2 = uploadFile(region, bucketName, "test-2", "TEST-2-A")
-		deletedVersionOfTest2 = deleteFile(region, bucketName, "test-2")
+		deleteMarkerOfTest2 = deleteFile(region, bucketName, "test-2")
 	})
 
 	AfterEach(func() {
@@ -44,7 +52,9 @@ var _ = Describe("S3Bucket", func() {
 	})
 
 	JustBeforeEach(func() {
-		bucket = NewS3Bucket("aws", bucketName, region, creds)
+		var err error
+		bucket, err = NewS3Bucket(bucketName, region, endpoint, S3Credentials{AccessKey: creds}, S3Credentials{}, 2)
+		Expect(err).NotTo(HaveOccurred())
 	})
 
 	Describe("Versions", func() {
@@ -65,42 +75,76 @@ var _ = Describe("S3Bucket", func() {
 
 			It("returns a list of all versions in the bucket", func() {
 				Expect(err).NotTo(HaveOccurred())
+
+				for i := range versions {
+					Expect(versions[i].LastModified).To(BeTemporally("~", time.Now(), time.Hour))
+					versions[i].LastModified = time.Time{}
+				}
 				Expect(versions).To(ConsistOf(
 					Version{Id: firstVersionOfTest1, Key: "test-1", IsLatest: false},
 					Version{Id: secondVersionOfTest1, Key: "test-1", IsLatest: false},
 					Version{Id: thirdVersionOfTest1, Key: "test-1", IsLatest: true},
 					Version{Id: firstVersionOfTest2, Key: "test-2", IsLatest: false},
+					Version{Id: deleteMarkerOfTest2, Key: "test-2", IsLatest: true, IsDeleteMarker: true},
 				))
 			})
 		})
 
 		Context("when retrieving versions fails", func() {
 			BeforeEach(func() {
-				creds = S3AccessKey{}
+				creds = S3AccessKey{Id: "invalid-access-key-id", Secret: "invalid-secret"}
 			})
 
 			It("returns the error", func() {
 				Expect(versions).To(BeNil())
-				Expect(err).To(MatchError(ContainSubstring("An error occurred")))
+				Expect(err).To(MatchError(ContainSubstring("InvalidAccessKeyId")))
 			})
 		})
 	})
 
-	Describe("PutVersions", func() {
+	Describe("CheckVersioning", func() {
 		var err error
 
-		BeforeEach(func() {
-			uploadFile(region, bucketName, "test-3", "TEST-3-A")
+		JustBeforeEach(func() {
+			err = bucket.CheckVersioning()
+		})
+
+		Context("when versioning is enabled", func() {
+			BeforeEach(func() {
+				creds = S3AccessKey{
+					Id:     os.Getenv("AWS_ACCESS_KEY_ID"),
+					Secret: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				}
+			})
+
+			It("succeeds", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when getting the versioning status fails", func() {
+			BeforeEach(func() {
+				creds = S3AccessKey{Id: "invalid-access-key-id", Secret: "invalid-secret"}
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError(ContainSubstring("failed to get the versioning status of bucket 'bbr-integration-test-bucket'")))
+				Expect(err).To(MatchError(ContainSubstring("InvalidAccessKeyId")))
+			})
 		})
+	})
+
+	Describe("CopyVersions", func() {
+		var err error
 
 		JustBeforeEach(func() {
-			err = bucket.PutVersions(region, bucketName, []LatestVersion{
+			err = bucket.CopyVersions(region, bucketName, []LatestVersion{
 				{BlobKey: "test-1", Id: secondVersionOfTest1},
 				{BlobKey: "test-2", Id: firstVersionOfTest2},
 			})
 		})
 
-		Context("when putting versions succeeds", func() {
+		Context("when copying versions succeeds", func() {
 			BeforeEach(func() {
 				creds = S3AccessKey{
 					Id:     os.Getenv("AWS_ACCESS_KEY_ID"),
@@ -108,7 +152,7 @@ var _ = Describe("S3Bucket", func() {
 				}
 			})
 
-			It("restores the versions to the specified ones", func() {
+			It("makes the specified versions current", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(listFiles(region, bucketName)).To(ConsistOf("test-1", "test-2"))
@@ -117,153 +161,911 @@ var _ = Describe("S3Bucket", func() {
 			})
 		})
 
-		Context("when putting versions fails", func() {
+		Context("when copying versions fails", func() {
+			BeforeEach(func() {
+				creds = S3AccessKey{Id: "invalid-access-key-id", Secret: "invalid-secret"}
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError(ContainSubstring("InvalidAccessKeyId")))
+			})
+		})
+	})
+
+	Describe("DeleteFiles", func() {
+		var err error
+
+		BeforeEach(func() {
+			uploadFile(region, bucketName, "test-3", "TEST-3-A")
+		})
+
+		JustBeforeEach(func() {
+			err = bucket.DeleteFiles([]string{"test-3"})
+		})
+
+		Context("when deleting files succeeds", func() {
+			BeforeEach(func() {
+				creds = S3AccessKey{
+					Id:     os.Getenv("AWS_ACCESS_KEY_ID"),
+					Secret: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				}
+			})
+
+			It("deletes the specified files", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(listFiles(region, bucketName)).To(ConsistOf("test-1"))
+			})
+		})
+
+		Context("when deleting files fails", func() {
+			BeforeEach(func() {
+				creds = S3AccessKey{Id: "invalid-access-key-id", Secret: "invalid-secret"}
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError(ContainSubstring("InvalidAccessKeyId")))
+			})
+		})
+	})
+})
+
+var _ = Describe("S3Bucket against a paginating S3 API", func() {
+	var server *ghttp.Server
+	var bucket S3Bucket
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		var err error
+		bucket, err = NewS3Bucket("paginated-bucket", "eu-west-1", S3Endpoint{URL: server.URL(), UsePathStyle: true}, S3Credentials{AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}, S3Credentials{}, 1)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("Versions", func() {
+		Context("when the versions span several pages", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/paginated-bucket"),
+						ghttp.VerifyFormKV("versions", ""),
+						ghttp.RespondWith(http.StatusOK, listVersionsPage(true, "key-2", "version-2",
+							`<Version><Key>key-1</Key><VersionId>version-1</VersionId><IsLatest>true</IsLatest></Version>`,
+							`<Version><Key>key-2</Key><VersionId>version-2</VersionId><IsLatest>true</IsLatest></Version>`,
+						)),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/paginated-bucket"),
+						ghttp.VerifyFormKV("key-marker", "key-2"),
+						ghttp.VerifyFormKV("version-id-marker", "version-2"),
+						ghttp.RespondWith(http.StatusOK, listVersionsPage(false, "", "",
+							`<Version><Key>key-3</Key><VersionId>version-3</VersionId><IsLatest>true</IsLatest></Version>`,
+							`<DeleteMarker><Key>key-4</Key><VersionId>version-4</VersionId><IsLatest>true</IsLatest><LastModified>2017-11-20T10:30:00.000Z</LastModified></DeleteMarker>`,
+						)),
+					),
+				)
+			})
+
+			It("returns the versions from every page", func() {
+				versions, err := bucket.Versions()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+				Expect(versions).To(Equal([]Version{
+					{Key: "key-1", Id: "version-1", IsLatest: true},
+					{Key: "key-2", Id: "version-2", IsLatest: true},
+					{Key: "key-3", Id: "version-3", IsLatest: true},
+					{Key: "key-4", Id: "version-4", IsLatest: true, IsDeleteMarker: true, LastModified: time.Date(2017, 11, 20, 10, 30, 0, 0, time.UTC)},
+				}))
+			})
+		})
+
+		Context("when fetching a later page fails", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusOK, listVersionsPage(true, "key-1", "version-1",
+						`<Version><Key>key-1</Key><VersionId>version-1</VersionId><IsLatest>true</IsLatest></Version>`,
+					)),
+					ghttp.RespondWith(http.StatusForbidden, s3Error("AccessDenied")),
+				)
+			})
+
+			It("returns an error instead of a partial listing", func() {
+				versions, err := bucket.Versions()
+
+				Expect(versions).To(BeNil())
+				Expect(err).To(MatchError(ContainSubstring("failed to list versions of bucket 'paginated-bucket'")))
+				Expect(err).To(MatchError(ContainSubstring("AccessDenied")))
+			})
+		})
+
+		Context("when a page is truncated but has no next marker", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusOK, listVersionsPage(true, "", "",
+						`<Version><Key>key-1</Key><VersionId>version-1</VersionId><IsLatest>true</IsLatest></Version>`,
+					)),
+				)
+			})
+
+			It("returns an error instead of a partial listing", func() {
+				versions, err := bucket.Versions()
+
+				Expect(versions).To(BeNil())
+				Expect(err).To(MatchError("failed to list versions of bucket 'paginated-bucket': " +
+					"listing was truncated but no next marker was returned"))
+			})
+		})
+	})
+
+})
+
+var _ = Describe("S3Bucket with several workers", func() {
+	var server *ghttp.Server
+	var bucket S3Bucket
+	var versions []LatestVersion
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		var err error
+		bucket, err = NewS3Bucket("parallel-bucket", "eu-west-1", S3Endpoint{URL: server.URL(), UsePathStyle: true}, S3Credentials{AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}, S3Credentials{}, 2)
+		Expect(err).NotTo(HaveOccurred())
+
+		versions = []LatestVersion{}
+		for i := 1; i <= 6; i++ {
+			versions = append(versions, LatestVersion{BlobKey: fmt.Sprintf("key-%d", i), Id: fmt.Sprintf("version-%d", i)})
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when copying succeeds", func() {
+		var inFlight int32
+		var maxInFlight int32
+
+		BeforeEach(func() {
+			inFlight = 0
+			maxInFlight = 0
+
+			server.RouteToHandler("PUT", regexp.MustCompile("^/parallel-bucket/key-"), func(w http.ResponseWriter, r *http.Request) {
+				current := atomic.AddInt32(&inFlight, 1)
+				for {
+					max := atomic.LoadInt32(&maxInFlight)
+					if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+						break
+					}
+				}
+
+				time.Sleep(50 * time.Millisecond)
+				atomic.AddInt32(&inFlight, -1)
+
+				w.Write([]byte(`<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`))
+			})
+		})
+
+		It("copies every version without exceeding the number of workers", func() {
+			err := bucket.CopyVersions("eu-west-1", "parallel-bucket", versions)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.ReceivedRequests()).To(HaveLen(6))
+			Expect(maxInFlight).To(Equal(int32(2)))
+		})
+	})
+
+	Context("when several copies fail", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("PUT", regexp.MustCompile("^/parallel-bucket/key-[25]$"),
+				ghttp.RespondWith(http.StatusForbidden, s3Error("AccessDenied")))
+			server.RouteToHandler("PUT", regexp.MustCompile("^/parallel-bucket/key-[1346]$"),
+				ghttp.RespondWith(http.StatusOK, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`))
+		})
+
+		It("reports every failure", func() {
+			err := bucket.CopyVersions("eu-west-1", "parallel-bucket", versions)
+
+			Expect(err).To(MatchError(ContainSubstring("failed to copy versions to bucket 'parallel-bucket' (2 error(s))")))
+			Expect(err).To(MatchError(ContainSubstring("failed to copy version 'version-2' of 'key-2'")))
+			Expect(err).To(MatchError(ContainSubstring("failed to copy version 'version-5' of 'key-5'")))
+			Expect(server.ReceivedRequests()).To(HaveLen(6))
+		})
+	})
+
+	Context("when there are more files to delete than fit in one request", func() {
+		var keys []string
+		var deletedKeys []string
+		var batchSizes []int
+		var batchesMutex sync.Mutex
+
+		BeforeEach(func() {
+			keys = []string{}
+			for i := 0; i < 1001; i++ {
+				keys = append(keys, fmt.Sprintf("extra-key-%04d", i))
+			}
+			deletedKeys = []string{}
+			batchSizes = []int{}
+
+			server.RouteToHandler("POST", "/parallel-bucket", func(w http.ResponseWriter, r *http.Request) {
+				batch := deleteRequestKeys(r)
+
+				batchesMutex.Lock()
+				batchSizes = append(batchSizes, len(batch))
+				deletedKeys = append(deletedKeys, batch...)
+				batchesMutex.Unlock()
+
+				w.Write([]byte(deleteResult()))
+			})
+		})
+
+		It("deletes them in batches of at most 1000 keys", func() {
+			err := bucket.DeleteFiles(keys)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.ReceivedRequests()).To(HaveLen(2))
+			Expect(batchSizes).To(ConsistOf(1000, 1))
+			Expect(deletedKeys).To(ConsistOf(keys))
+		})
+	})
+
+	Context("when deleting some of the files fails", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					verifyDeletedKeys("key-1", "key-2", "key-3"),
+					ghttp.RespondWith(http.StatusOK, deleteResult(
+						`<Error><Key>key-1</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`,
+						`<Error><Key>key-2</Key><Code>InternalError</Code><Message>Please try again</Message></Error>`,
+					)),
+				),
+			)
+		})
+
+		It("reports every key that failed", func() {
+			err := bucket.DeleteFiles([]string{"key-1", "key-2", "key-3"})
+
+			Expect(err).To(MatchError(ContainSubstring("failed to delete files from bucket 'parallel-bucket'")))
+			Expect(err).To(MatchError(ContainSubstring("failed to delete 2 of 3 file(s)")))
+			Expect(err).To(MatchError(ContainSubstring("failed to delete 'key-1': AccessDenied: Access Denied")))
+			Expect(err).To(MatchError(ContainSubstring("failed to delete 'key-2': InternalError: Please try again")))
+		})
+	})
+
+	Context("when a delete request fails", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusForbidden, s3Error("AccessDenied")),
+			)
+		})
+
+		It("returns the error", func() {
+			err := bucket.DeleteFiles([]string{"key-1", "key-2"})
+
+			Expect(err).To(MatchError(ContainSubstring("failed to delete 2 file(s) starting at 'key-1'")))
+			Expect(err).To(MatchError(ContainSubstring("AccessDenied")))
+		})
+	})
+})
+
+var _ = Describe("S3Bucket restoring from a bucket in another region", func() {
+	var server *ghttp.Server
+	var bucket S3Bucket
+	var sourceCredentials S3Credentials
+	var err error
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		sourceCredentials = S3Credentials{}
+	})
+
+	JustBeforeEach(func() {
+		bucket, err = NewS3Bucket("dr-bucket", "eu-west-1", S3Endpoint{URL: server.URL(), UsePathStyle: true}, S3Credentials{AccessKey: S3AccessKey{Id: "dr-id", Secret: "dr-secret"}},
+			sourceCredentials, 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = bucket.CopyVersions("us-east-1", "primary-bucket", []LatestVersion{
+			{BlobKey: "key-1", Id: "version-1"},
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when no source credentials are configured", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/dr-bucket/key-1"),
+					ghttp.VerifyHeaderKV("X-Amz-Copy-Source", "primary-bucket/key-1?versionId=version-1"),
+					verifySignedBy("dr-id", "eu-west-1"),
+					ghttp.RespondWith(http.StatusOK, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`),
+				),
+			)
+		})
+
+		It("copies the versions on the server with the bucket's own credentials", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when source credentials are configured", func() {
+		BeforeEach(func() {
+			sourceCredentials = S3Credentials{AccessKey: S3AccessKey{Id: "primary-id", Secret: "primary-secret"}}
+		})
+
+		Context("and transferring the versions succeeds", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/primary-bucket/key-1", "versionId=version-1"),
+						verifySignedBy("primary-id", "us-east-1"),
+						ghttp.RespondWith(http.StatusOK, "KEY-1-CONTENTS"),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/dr-bucket/key-1"),
+						verifySignedBy("dr-id", "eu-west-1"),
+						ghttp.VerifyBody([]byte("KEY-1-CONTENTS")),
+						ghttp.RespondWith(http.StatusOK, ""),
+					),
+				)
+			})
+
+			It("reads them with the source credentials and writes them with the bucket's own", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and a version has headers and user metadata", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusOK, "KEY-1-CONTENTS", http.Header{
+						"Content-Type":     {"application/zip"},
+						"Content-Encoding": {"gzip"},
+						"Cache-Control":    {"max-age=3600"},
+						"X-Amz-Meta-Owner": {"cloud-controller"},
+					}),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/dr-bucket/key-1"),
+						ghttp.VerifyHeaderKV("Content-Type", "application/zip"),
+						ghttp.VerifyHeaderKV("Content-Encoding", "gzip"),
+						ghttp.VerifyHeaderKV("Cache-Control", "max-age=3600"),
+						ghttp.VerifyHeaderKV("X-Amz-Meta-Owner", "cloud-controller"),
+						ghttp.VerifyBody([]byte("KEY-1-CONTENTS")),
+						ghttp.RespondWith(http.StatusOK, ""),
+					),
+				)
+			})
+
+			It("writes them along with the contents, without decoding the contents", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and reading a version fails", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusForbidden, s3Error("AccessDenied")),
+				)
+			})
+
+			It("returns the error without writing anything", func() {
+				Expect(err).To(MatchError(ContainSubstring("failed to download version 'version-1' of 'key-1'")))
+				Expect(err).To(MatchError(ContainSubstring("AccessDenied")))
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+
+		Context("and writing a version fails", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusOK, "KEY-1-CONTENTS"),
+					ghttp.RespondWith(http.StatusForbidden, s3Error("AccessDenied")),
+				)
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError(ContainSubstring("failed to upload version 'version-1' of 'key-1'")))
+				Expect(err).To(MatchError(ContainSubstring("AccessDenied")))
+			})
+		})
+	})
+})
+
+var _ = Describe("S3Bucket copying versions from another bucket", func() {
+	var server *ghttp.Server
+	var bucket S3Bucket
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+
+		var err error
+		bucket, err = NewS3Bucket("this-bucket", "eu-west-1", S3Endpoint{URL: server.URL(), UsePathStyle: true},
+			S3Credentials{AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}, S3Credentials{}, 1)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Describe("CopyVersions", func() {
+		Context("when versioning is still enabled on the other bucket", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/other-bucket", "versioning="),
+						verifySignedBy("id", "us-east-1"),
+						ghttp.RespondWith(http.StatusOK, versioningConfiguration("<Status>Enabled</Status>")),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/this-bucket/key-1"),
+						ghttp.VerifyHeaderKV("X-Amz-Copy-Source", "other-bucket/key-1?versionId=null"),
+						ghttp.RespondWith(http.StatusOK, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`),
+					),
+				)
+			})
+
+			It("copies a `null` version, written before versioning was enabled, by its id", func() {
+				err := bucket.CopyVersions("us-east-1", "other-bucket", []LatestVersion{
+					{BlobKey: "key-1", Id: "null"},
+				})
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when versioning has since been suspended on the other bucket", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/other-bucket", "versioning="),
+						ghttp.RespondWith(http.StatusOK, versioningConfiguration("<Status>Suspended</Status>")),
+					),
+				)
+			})
+
+			It("fails without copying a `null` version, as it may name a newer blob", func() {
+				err := bucket.CopyVersions("us-east-1", "other-bucket", []LatestVersion{
+					{BlobKey: "key-1", Id: "version-1"},
+					{BlobKey: "key-2", Id: "null"},
+				})
+
+				Expect(err).To(MatchError("cannot copy the `null` versions from bucket 'other-bucket': " +
+					"versioning is suspended on bucket 'other-bucket'"))
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("CopyVersionsToPrefix", func() {
+		Context("when the other bucket is versioned", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/this-bucket/2017-11-20T10-00-00Z/droplets/key-1"),
+						ghttp.VerifyHeaderKV("X-Amz-Copy-Source", "other-bucket/key-1?versionId=version-1"),
+						ghttp.RespondWith(http.StatusOK, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`),
+					),
+				)
+			})
+
+			It("copies each version to its key under the prefix", func() {
+				err := bucket.CopyVersionsToPrefix("us-east-1", "other-bucket", "2017-11-20T10-00-00Z/droplets/", []LatestVersion{
+					{BlobKey: "key-1", Id: "version-1"},
+				})
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+
+		Context("when versioning has never been enabled on the other bucket", func() {
 			BeforeEach(func() {
-				creds = S3AccessKey{}
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/this-bucket/2017-11-20T10-00-00Z/droplets/key-1"),
+						ghttp.VerifyHeaderKV("X-Amz-Copy-Source", "other-bucket/key-1?versionId=null"),
+						ghttp.RespondWith(http.StatusOK, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`),
+					),
+				)
+			})
+
+			It("copies the `null` versions just listed without checking versioning", func() {
+				err := bucket.CopyVersionsToPrefix("us-east-1", "other-bucket", "2017-11-20T10-00-00Z/droplets/", []LatestVersion{
+					{BlobKey: "key-1", Id: "null"},
+				})
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+	})
+
+	Describe("CopyVersionsFromPrefix", func() {
+		Context("when copying succeeds", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/this-bucket/key-1"),
+						ghttp.VerifyHeaderKV("X-Amz-Copy-Source", "other-bucket/2017-11-20T10-00-00Z%2Fdroplets%2Fkey-1"),
+						ghttp.RespondWith(http.StatusOK, `<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`),
+					),
+				)
+			})
+
+			It("copies the latest version from under the prefix to its key", func() {
+				err := bucket.CopyVersionsFromPrefix("us-east-1", "other-bucket", "2017-11-20T10-00-00Z/droplets/", []LatestVersion{
+					{BlobKey: "key-1"},
+				})
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+		})
+
+		Context("when copying fails", func() {
+			BeforeEach(func() {
+				server.AppendHandlers(
+					ghttp.RespondWith(http.StatusForbidden, s3Error("AccessDenied")),
+				)
 			})
 
 			It("returns the error", func() {
-				Expect(err).To(MatchError(ContainSubstring("An error occurred")))
+				err := bucket.CopyVersionsFromPrefix("us-east-1", "other-bucket", "2017-11-20T10-00-00Z/droplets/", []LatestVersion{
+					{BlobKey: "key-1"},
+				})
+
+				Expect(err).To(MatchError(ContainSubstring("failed to copy 'key-1'")))
+				Expect(err).To(MatchError(ContainSubstring("AccessDenied")))
 			})
 		})
 	})
 })
 
+var _ = Describe("S3Bucket versioning status", func() {
+	var server *ghttp.Server
+	var err error
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+	})
+
+	JustBeforeEach(func() {
+		var bucket S3Bucket
+		bucket, err = NewS3Bucket("unversioned-bucket", "eu-west-1", S3Endpoint{URL: server.URL(), UsePathStyle: true},
+			S3Credentials{AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}, S3Credentials{}, 1)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = bucket.CheckVersioning()
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when versioning is suspended", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/unversioned-bucket", "versioning="),
+					ghttp.RespondWith(http.StatusOK, versioningConfiguration("<Status>Suspended</Status>")),
+				),
+			)
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError("versioning is suspended on bucket 'unversioned-bucket'"))
+		})
+	})
+
+	Context("when versioning has never been enabled", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.RespondWith(http.StatusOK, versioningConfiguration("")),
+			)
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError("versioning has never been enabled on bucket 'unversioned-bucket'"))
+		})
+	})
+})
+
+var _ = Describe("S3Bucket credential sources", func() {
+	var s3Credentials S3Credentials
+	var err error
+
+	JustBeforeEach(func() {
+		_, err = NewS3Bucket("bucket", "eu-west-1", S3Endpoint{}, s3Credentials, S3Credentials{}, 1)
+	})
+
+	Context("when the credentials are static", func() {
+		BeforeEach(func() {
+			s3Credentials = S3Credentials{Source: "static", AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}
+		})
+
+		It("succeeds", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when the credentials come from the instance profile", func() {
+		BeforeEach(func() {
+			s3Credentials = S3Credentials{Source: "instance_profile"}
+		})
+
+		It("succeeds", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when the credentials come from assuming a role", func() {
+		BeforeEach(func() {
+			s3Credentials = S3Credentials{
+				Source:     "assume_role",
+				RoleARN:    "arn:aws:iam::123456789012:role/blobstore-backup",
+				ExternalId: "external-id",
+			}
+		})
+
+		It("succeeds", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when assuming a role without a role ARN", func() {
+		BeforeEach(func() {
+			s3Credentials = S3Credentials{Source: "assume_role"}
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError("missing role ARN for the assume_role credential source"))
+		})
+	})
+
+	Context("when the credential source is not supported", func() {
+		BeforeEach(func() {
+			s3Credentials = S3Credentials{Source: "magic"}
+		})
+
+		It("returns an error", func() {
+			Expect(err).To(MatchError("unsupported credential source 'magic'"))
+		})
+	})
+})
+
+var _ = Describe("S3Bucket against an S3-compatible endpoint with its own CA", func() {
+	var server *ghttp.Server
+	var caCert string
+
+	BeforeEach(func() {
+		server = ghttp.NewTLSServer()
+		caCert = string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: server.HTTPTestServer.Certificate().Raw,
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when the CA certificate is configured", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/on-prem-bucket", "versions="),
+					ghttp.RespondWith(http.StatusOK, listVersionsPage(false, "", "",
+						`<Version><Key>key-1</Key><VersionId>version-1</VersionId><IsLatest>true</IsLatest></Version>`,
+					)),
+				),
+			)
+		})
+
+		It("trusts the endpoint", func() {
+			bucket, err := NewS3Bucket("on-prem-bucket", "us-east-1",
+				S3Endpoint{URL: server.URL(), UsePathStyle: true, CACert: caCert},
+				S3Credentials{AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}, S3Credentials{}, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			versions, err := bucket.Versions()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(versions).To(ConsistOf(Version{Key: "key-1", Id: "version-1", IsLatest: true}))
+		})
+	})
+
+	Context("when the CA certificate is not configured", func() {
+		It("refuses to talk to the endpoint", func() {
+			bucket, err := NewS3Bucket("on-prem-bucket", "us-east-1",
+				S3Endpoint{URL: server.URL(), UsePathStyle: true},
+				S3Credentials{AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}, S3Credentials{}, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = bucket.Versions()
+
+			Expect(err).To(MatchError(ContainSubstring("certificate")))
+			Expect(server.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when the CA certificate is not valid PEM", func() {
+		It("returns an error", func() {
+			_, err := NewS3Bucket("on-prem-bucket", "us-east-1",
+				S3Endpoint{URL: server.URL(), UsePathStyle: true, CACert: "not a certificate"},
+				S3Credentials{AccessKey: S3AccessKey{Id: "id", Secret: "secret"}}, S3Credentials{}, 1)
+
+			Expect(err).To(MatchError(ContainSubstring("failed to load custom CA bundle PEM file")))
+		})
+	})
+})
+
+func verifySignedBy(accessKeyId, regionName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		Expect(r.Header.Get("Authorization")).To(ContainSubstring(
+			fmt.Sprintf("Credential=%s/", accessKeyId)))
+		Expect(r.Header.Get("Authorization")).To(ContainSubstring(
+			fmt.Sprintf("/%s/s3/aws4_request", regionName)))
+	}
+}
+
+func listVersionsPage(isTruncated bool, nextKeyMarker, nextVersionIdMarker string, versions ...string) string {
+	markers := ""
+	if nextKeyMarker != "" {
+		markers = fmt.Sprintf("<NextKeyMarker>%s</NextKeyMarker><NextVersionIdMarker>%s</NextVersionIdMarker>",
+			nextKeyMarker, nextVersionIdMarker)
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>paginated-bucket</Name>
+  <IsTruncated>%t</IsTruncated>
+  %s
+  %s
+</ListVersionsResult>`, isTruncated, markers, strings.Join(versions, "\n"))
+}
+
+func versioningConfiguration(status string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">%s</VersioningConfiguration>`, status)
+}
+
+func deleteResult(errors ...string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<DeleteResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  %s
+</DeleteResult>`, strings.Join(errors, "\n"))
+}
+
+func verifyDeletedKeys(keys ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		Expect(deleteRequestKeys(r)).To(ConsistOf(keys))
+	}
+}
+
+func deleteRequestKeys(r *http.Request) []string {
+	body, err := ioutil.ReadAll(r.Body)
+	Expect(err).NotTo(HaveOccurred())
+
+	var request DeleteRequest
+	Expect(xml.Unmarshal(body, &request)).To(Succeed())
+
+	keys := []string{}
+	for _, object := range request.Objects {
+		keys = append(keys, object.Key)
+	}
+
+	return keys
+}
+
+type DeleteRequest struct {
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+func s3Error(code string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
 func listFiles(region, bucket string) []string {
-	outputBuffer := new(bytes.Buffer)
-
-	awsCmd := exec.Command("aws",
-		"--region", region,
-		"s3api",
-		"list-objects",
-		"--bucket", bucket)
-	awsCmd.Stdout = outputBuffer
-	err := awsCmd.Run()
+	output, err := testS3Client(region).ListObjects(&s3.ListObjectsInput{
+		Bucket: aws.String(bucket),
+	})
 	Expect(err).ToNot(HaveOccurred())
 
-	var response ListResponse
-	json.Unmarshal(outputBuffer.Bytes(), &response)
-
 	keys := []string{}
-	for _, entry := range response.Contents {
-		keys = append(keys, entry.Key)
+	for _, entry := range output.Contents {
+		keys = append(keys, aws.StringValue(entry.Key))
 	}
 
 	return keys
 }
 
 func getFileContents(region, bucket, key string) string {
-	outputBuffer := new(bytes.Buffer)
-
-	awsCmd := exec.Command("aws",
-		"--region", region,
-		"s3",
-		"cp",
-		fmt.Sprintf("s3://%s/%s", bucket, key),
-		"-")
-	awsCmd.Stdout = outputBuffer
-	err := awsCmd.Run()
+	output, err := testS3Client(region).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	Expect(err).ToNot(HaveOccurred())
+	defer output.Body.Close()
+
+	body, err := ioutil.ReadAll(output.Body)
 	Expect(err).ToNot(HaveOccurred())
 
-	return outputBuffer.String()
+	return string(body)
 }
 
 func uploadFile(region, bucket, key, body string) string {
-	bodyFile, _ := ioutil.TempFile("", key)
-	bodyFile.WriteString(body)
-	bodyFile.Close()
-
-	outputBuffer := new(bytes.Buffer)
-
-	awsCmd := exec.Command("aws",
-		"--region", region,
-		"s3api",
-		"put-object",
-		"--bucket", bucket,
-		"--key", key,
-		"--body", bodyFile.Name())
-	awsCmd.Stdout = outputBuffer
-	err := awsCmd.Run()
+	output, err := testS3Client(region).PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(body),
+	})
 	Expect(err).ToNot(HaveOccurred())
 
-	var response PutResponse
-	json.Unmarshal(outputBuffer.Bytes(), &response)
-
-	return response.VersionId
+	return aws.StringValue(output.VersionId)
 }
 
 func deleteFile(region, bucket, key string) string {
-	outputBuffer := new(bytes.Buffer)
-
-	awsCmd := exec.Command("aws",
-		"--region", region,
-		"s3api",
-		"delete-object",
-		"--bucket", bucket,
-		"--key", key)
-	awsCmd.Stdout = outputBuffer
-	err := awsCmd.Run()
+	output, err := testS3Client(region).DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
 	Expect(err).ToNot(HaveOccurred())
 
-	var response PutResponse
-	json.Unmarshal(outputBuffer.Bytes(), &response)
-
-	return response.VersionId
+	return aws.StringValue(output.VersionId)
 }
 
 func deleteVersion(region, bucket, key, versionId string) {
-	err := exec.Command("aws",
-		"--region", region,
-		"s3api",
-		"delete-object",
-		"--bucket", bucket,
-		"--key", key,
-		"--version-id", versionId).Run()
+	_, err := testS3Client(region).DeleteObject(&s3.DeleteObjectInput{
+		Bucket:    aws.String(bucket),
+		Key:       aws.String(key),
+		VersionId: aws.String(versionId),
+	})
 	Expect(err).NotTo(HaveOccurred())
 }
 
 func deleteAllVersions(region, bucket string) {
-	outputBuffer := new(bytes.Buffer)
-
-	awsCmd := exec.Command("aws",
-		"--region", region,
-		"s3api",
-		"list-object-versions",
-		"--bucket", bucket)
-	awsCmd.Stdout = outputBuffer
-	err := awsCmd.Run()
+	output, err := testS3Client(region).ListObjectVersions(&s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+	})
 	Expect(err).ToNot(HaveOccurred())
 
-	var response VersionsResponse
-	json.Unmarshal(outputBuffer.Bytes(), &response)
-
-	for _, version := range response.Versions {
-		deleteVersion(region, bucket, version.Key, version.VersionId)
+	for _, version := range output.Versions {
+		deleteVersion(region, bucket, aws.StringValue(version.Key), aws.StringValue(version.VersionId))
 	}
 
-	for _, version := range response.DeleteMarkers {
-		deleteVersion(region, bucket, version.Key, version.VersionId)
+	for _, version := range output.DeleteMarkers {
+		deleteVersion(region, bucket, aws.StringValue(version.Key), aws.StringValue(version.VersionId))
 	}
 }
 
-type PutResponse struct {
-	VersionId string
-}
-
-type VersionsResponse struct {
-	Versions      []VersionItem
-	DeleteMarkers []VersionItem
-}
-
-type VersionItem struct {
-	Key       string
-	VersionId string
-}
+func testS3Client(region string) *s3.S3 {
+	config := &aws.Config{
+		Region: aws.String(region),
+		Credentials: credentials.NewStaticCredentials(
+			os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			"",
+		),
+	}
 
-type ListResponse struct {
-	Contents []ListResponseEntry
-}
+	if endpoint := os.Getenv("S3_ENDPOINT"); endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
 
-type ListResponseEntry struct {
-	Key string
+	return s3.New(session.Must(session.NewSession(config)))
 }
@@ -2,13 +2,16 @@ package main
 
 import (
 	"log"
-	"os"
 
 	"encoding/json"
 	"io/ioutil"
 
 	"errors"
 	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/cloudfoundry-incubator/blobstore-backup-restore"
 )
@@ -19,10 +22,6 @@ func main() {
 		log.Fatal(err.Error())
 	}
 
-	awsCliPath := getEnv("AWS_CLI_PATH")
-
-	artifact := blobstore.NewFileArtifact(commandFlags.ArtifactFilePath)
-
 	config, err := ioutil.ReadFile(commandFlags.ConfigPath)
 	if err != nil {
 		log.Fatal("Failed to read config")
@@ -34,12 +33,49 @@ func main() {
 		log.Fatal("Failed to parse config")
 	}
 
-	buckets := makeBuckets(awsCliPath, bucketsConfig)
+	buckets, backupBuckets, err := makeBuckets(bucketsConfig)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	fileArtifact := blobstore.NewFileArtifact(commandFlags.ArtifactFilePath)
+	if commandFlags.EncryptionKeyPath != "" {
+		encryptionKey, err := ioutil.ReadFile(commandFlags.EncryptionKeyPath)
+		if err != nil {
+			log.Fatal("Failed to read encryption key")
+		}
+		trimmedEncryptionKey := strings.TrimSpace(string(encryptionKey))
+		if trimmedEncryptionKey == "" {
+			log.Fatal("Encryption key is empty")
+		}
+		fileArtifact = blobstore.NewEncryptedFileArtifact(commandFlags.ArtifactFilePath, trimmedEncryptionKey)
+	}
+	if commandFlags.RequireChecksum {
+		fileArtifact = fileArtifact.RequireChecksum()
+	}
+
+	var artifact blobstore.Artifact = fileArtifact
+	if !commandFlags.AsOf.IsZero() {
+		artifact = blobstore.NewPointInTimeArtifact(buckets, commandFlags.AsOf)
+	}
+	backuper := blobstore.NewBackuper(buckets, backupBuckets, artifact)
 
-	if commandFlags.IsRestore {
-		err = blobstore.NewRestorer(buckets, artifact).Restore()
+	if commandFlags.IsValidate {
+		err = blobstore.NewVersioningValidator(backuper.VersionedBuckets()).Validate()
+	} else if commandFlags.IsDryRun {
+		var plans map[string]blobstore.RestorePlan
+		plans, err = blobstore.NewRestorer(buckets, artifact, commandFlags.RestoreOptions).Plan()
+		if err == nil {
+			err = printRestorePlans(plans, commandFlags.IsJSON)
+		}
+	} else if commandFlags.IsRestore {
+		var summaries map[string]blobstore.RestoreSummary
+		summaries, err = blobstore.NewRestorer(buckets, artifact, commandFlags.RestoreOptions).Restore()
+		if err == nil {
+			printRestoreSummaries(summaries)
+		}
 	} else {
-		err = blobstore.NewBackuper(buckets, artifact).Backup()
+		err = backuper.Backup()
 	}
 
 	if err != nil {
@@ -47,72 +83,275 @@ func main() {
 	}
 }
 
-func getEnv(varName string) string {
-	value, exists := os.LookupEnv(varName)
-	if !exists {
-		log.Fatalf("Missing environment variable '%s'", varName)
+func printRestoreSummaries(summaries map[string]blobstore.RestoreSummary) {
+	identifiers := []string{}
+	for identifier := range summaries {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+
+	for _, identifier := range identifiers {
+		summary := summaries[identifier]
+		fmt.Printf("%s: %d unchanged, %d copied, %d undeleted, %d deleted\n",
+			identifier, summary.Unchanged, summary.Copied, summary.Undeleted, summary.Deleted)
+	}
+}
+
+func printRestorePlans(plans map[string]blobstore.RestorePlan, isJSON bool) error {
+	if isJSON {
+		marshalledPlans, err := json.MarshalIndent(plans, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(marshalledPlans))
+		return nil
+	}
+
+	identifiers := []string{}
+	for identifier := range plans {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+
+	for _, identifier := range identifiers {
+		plan := plans[identifier]
+		summary := plan.Summary()
+		fmt.Printf("%s: %d unchanged, %d to copy, %d to undelete, %d to delete\n",
+			identifier, summary.Unchanged, summary.Copied, summary.Undeleted, summary.Deleted)
+		if plan.ExceedsDeleteThreshold {
+			fmt.Println("  restore would be refused: it deletes more files than --max-deletes or --max-delete-percentage allow")
+		}
+		printKeys("unchanged", plan.Unchanged)
+		printKeys("copy", plan.Copied)
+		printKeys("undelete", plan.Undeleted)
+		printKeys("delete", plan.Deleted)
+	}
+
+	return nil
+}
+
+func printKeys(action string, keys []string) {
+	for _, key := range keys {
+		fmt.Printf("  %s: %s\n", action, key)
 	}
-	return value
 }
 
-func makeBuckets(awsCliPath string, config map[string]BucketConfig) map[string]blobstore.Bucket {
+func makeBuckets(config map[string]BucketConfig) (map[string]blobstore.Bucket, map[string]blobstore.Bucket, error) {
 	var buckets = map[string]blobstore.Bucket{}
+	var backupBuckets = map[string]blobstore.Bucket{}
 
 	for identifier, bucketConfig := range config {
-		buckets[identifier] = blobstore.NewS3Bucket(
-			awsCliPath,
-			bucketConfig.Name,
-			bucketConfig.Region,
-			blobstore.S3AccessKey{
-				Id:     bucketConfig.AwsAccessKeyId,
-				Secret: bucketConfig.AwsSecretAccessKey,
+		bucket, err := makeBucket(bucketConfig.Name, bucketConfig.Region, bucketConfig, blobstore.S3Credentials{
+			Source: bucketConfig.SourceCredentialSource,
+			AccessKey: blobstore.S3AccessKey{
+				Id:     bucketConfig.SourceAwsAccessKeyId,
+				Secret: bucketConfig.SourceAwsSecretAccessKey,
 			},
-		)
+			RoleARN:    bucketConfig.SourceRoleArn,
+			ExternalId: bucketConfig.SourceExternalId,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		buckets[identifier] = bucket
+
+		if bucketConfig.BackupBucketName != "" {
+			backupBucketRegion := bucketConfig.BackupBucketRegion
+			if backupBucketRegion == "" {
+				backupBucketRegion = bucketConfig.Region
+			}
+
+			backupBucket, err := makeBucket(bucketConfig.BackupBucketName, backupBucketRegion, bucketConfig, blobstore.S3Credentials{})
+			if err != nil {
+				return nil, nil, err
+			}
+
+			backupBuckets[identifier] = backupBucket
+		}
 	}
 
-	return buckets
+	return buckets, backupBuckets, nil
+}
+
+func makeBucket(name, region string, bucketConfig BucketConfig, sourceCredentials blobstore.S3Credentials) (blobstore.S3Bucket, error) {
+	return blobstore.NewS3Bucket(
+		name,
+		region,
+		blobstore.S3Endpoint{
+			URL:          bucketConfig.Endpoint,
+			UsePathStyle: bucketConfig.UsePathStyle,
+			CACert:       bucketConfig.CACert,
+		},
+		blobstore.S3Credentials{
+			Source: bucketConfig.CredentialSource,
+			AccessKey: blobstore.S3AccessKey{
+				Id:     bucketConfig.AwsAccessKeyId,
+				Secret: bucketConfig.AwsSecretAccessKey,
+			},
+			RoleARN:    bucketConfig.RoleArn,
+			ExternalId: bucketConfig.ExternalId,
+		},
+		sourceCredentials,
+		bucketConfig.Workers,
+	)
 }
 
 type BucketConfig struct {
-	Name               string `json:"name"`
-	Region             string `json:"region"`
-	AwsAccessKeyId     string `json:"aws_access_key_id"`
-	AwsSecretAccessKey string `json:"aws_secret_access_key"`
+	Name                     string `json:"name"`
+	Region                   string `json:"region"`
+	Endpoint                 string `json:"endpoint"`
+	UsePathStyle             bool   `json:"use_path_style"`
+	CACert                   string `json:"ca_cert"`
+	AwsAccessKeyId           string `json:"aws_access_key_id"`
+	AwsSecretAccessKey       string `json:"aws_secret_access_key"`
+	CredentialSource         string `json:"credential_source"`
+	RoleArn                  string `json:"role_arn"`
+	ExternalId               string `json:"external_id"`
+	SourceAwsAccessKeyId     string `json:"source_aws_access_key_id"`
+	SourceAwsSecretAccessKey string `json:"source_aws_secret_access_key"`
+	SourceCredentialSource   string `json:"source_credential_source"`
+	SourceRoleArn            string `json:"source_role_arn"`
+	SourceExternalId         string `json:"source_external_id"`
+	Workers                  int    `json:"workers"`
+	BackupBucketName         string `json:"backup_bucket_name"`
+	BackupBucketRegion       string `json:"backup_bucket_region"`
 }
 
 func parseFlags() (CommandFlags, error) {
 	var configFilePath = flag.String("config", "", "Path to JSON config file")
 	var backupAction = flag.Bool("backup", false, "Run blobstore backup")
 	var restoreAction = flag.Bool("restore", false, "Run blobstore restore")
+	var validateAction = flag.Bool("validate", false, "Check that the buckets can be backed up")
 	var artifactFilePath = flag.String("artifact-file", "", "Path to the artifact file")
+	var dryRun = flag.Bool("dry-run", false, "Print what a restore would do without changing the buckets")
+	var jsonOutput = flag.Bool("json", false, "Print the dry run as JSON")
+	var maxDeletes = flag.Int("max-deletes", 0, "Refuse to restore if more files than this would be deleted from a bucket (default no limit)")
+	var maxDeletePercentage = flag.Int("max-delete-percentage", 0, "Refuse to restore if more than this percentage of a bucket's files would be deleted (default no limit)")
+	var bucketIdentifiers identifierList
+	flag.Var(&bucketIdentifiers, "bucket", "Only restore the bucket with this identifier (can be repeated)")
+	var prefix = flag.String("prefix", "", "Only restore the files whose keys start with this prefix")
+	var skipUnmatchedBuckets = flag.Bool("skip-unmatched-buckets", false, "Only restore the buckets that are both configured and in the artifact")
+	var asOf = flag.String("as-of", "", "Restore the buckets to their state at this RFC3339 time, instead of from an artifact")
+	var encryptionKeyPath = flag.String("encryption-key-file", "", "Path to a file containing the key to encrypt the artifact with, or to decrypt it with on restore")
+	var requireChecksum = flag.Bool("require-checksum", false, "Fail to restore an artifact that has no checksum file, instead of restoring it without verification")
+	var allowMassDelete = flag.Bool("allow-mass-delete", false, "Restore even if it deletes more files than --max-deletes or --max-delete-percentage")
 
 	flag.Parse()
 
-	if *backupAction && *restoreAction {
-		return CommandFlags{}, errors.New("only one of: --backup or --restore can be provided")
+	actionCount := 0
+	for _, action := range []bool{*backupAction, *restoreAction, *validateAction} {
+		if action {
+			actionCount++
+		}
+	}
+
+	if actionCount > 1 {
+		return CommandFlags{}, errors.New("only one of: --backup, --restore or --validate can be provided")
 	}
 
-	if !*backupAction && !*restoreAction {
-		return CommandFlags{}, errors.New("missing --backup or --restore flag")
+	if actionCount == 0 {
+		return CommandFlags{}, errors.New("missing --backup, --restore or --validate flag")
 	}
 
 	if *configFilePath == "" {
 		return CommandFlags{}, errors.New("missing --config flag")
 	}
 
-	if *artifactFilePath == "" {
+	if *asOf != "" && !*restoreAction {
+		return CommandFlags{}, errors.New("--as-of can only be provided with --restore")
+	}
+
+	if *encryptionKeyPath != "" && *asOf != "" {
+		return CommandFlags{}, errors.New("--encryption-key-file cannot be provided with --as-of")
+	}
+
+	if *asOf != "" && *artifactFilePath != "" {
+		return CommandFlags{}, errors.New("only one of: --as-of or --artifact-file can be provided")
+	}
+
+	if *artifactFilePath == "" && *asOf == "" && !*validateAction {
 		return CommandFlags{}, errors.New("missing --artifact-file flag")
 	}
 
+	var asOfTime time.Time
+	if *asOf != "" {
+		var err error
+		asOfTime, err = time.Parse(time.RFC3339, *asOf)
+		if err != nil {
+			return CommandFlags{}, fmt.Errorf("--as-of must be an RFC3339 time: %s", err.Error())
+		}
+	}
+
+	if *dryRun && !*restoreAction {
+		return CommandFlags{}, errors.New("--dry-run can only be provided with --restore")
+	}
+
+	if *jsonOutput && !*dryRun {
+		return CommandFlags{}, errors.New("--json can only be provided with --dry-run")
+	}
+
+	if *skipUnmatchedBuckets && !*restoreAction {
+		return CommandFlags{}, errors.New("--skip-unmatched-buckets can only be provided with --restore")
+	}
+
+	if (len(bucketIdentifiers) != 0 || *prefix != "") && !*restoreAction {
+		return CommandFlags{}, errors.New("--bucket and --prefix can only be provided with --restore")
+	}
+
+	if *requireChecksum && (!*restoreAction || *asOf != "") {
+		return CommandFlags{}, errors.New("--require-checksum can only be provided when restoring from --artifact-file")
+	}
+
+	if *maxDeletes < 0 || *maxDeletePercentage < 0 {
+		return CommandFlags{}, errors.New("--max-deletes and --max-delete-percentage must not be negative")
+	}
+
+	deleteThreshold := blobstore.DeleteThreshold{MaxCount: *maxDeletes, MaxPercentage: *maxDeletePercentage}
+	if *allowMassDelete {
+		deleteThreshold = blobstore.DeleteThreshold{}
+	}
+
 	return CommandFlags{
-		ConfigPath:       *configFilePath,
-		IsRestore:        *restoreAction,
-		ArtifactFilePath: *artifactFilePath,
+		ConfigPath:        *configFilePath,
+		IsRestore:         *restoreAction,
+		IsValidate:        *validateAction,
+		IsDryRun:          *dryRun,
+		IsJSON:            *jsonOutput,
+		ArtifactFilePath:  *artifactFilePath,
+		EncryptionKeyPath: *encryptionKeyPath,
+		AsOf:              asOfTime,
+		RequireChecksum:   *requireChecksum,
+		RestoreOptions: blobstore.RestoreOptions{
+			DeleteThreshold:      deleteThreshold,
+			SkipUnmatchedBuckets: *skipUnmatchedBuckets,
+			Buckets:              bucketIdentifiers,
+			Prefix:               *prefix,
+		},
 	}, nil
 }
 
 type CommandFlags struct {
-	ConfigPath       string
-	IsRestore        bool
-	ArtifactFilePath string
+	ConfigPath        string
+	IsRestore         bool
+	IsValidate        bool
+	IsDryRun          bool
+	IsJSON            bool
+	ArtifactFilePath  string
+	EncryptionKeyPath string
+	AsOf              time.Time
+	RequireChecksum   bool
+	RestoreOptions    blobstore.RestoreOptions
+}
+
+type identifierList []string
+
+func (l *identifierList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *identifierList) Set(identifier string) error {
+	*l = append(*l, identifier)
+	return nil
 }
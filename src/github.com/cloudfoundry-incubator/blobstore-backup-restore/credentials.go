@@ -0,0 +1,90 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+const (
+	StaticCredentialSource          = "static"
+	InstanceProfileCredentialSource = "instance_profile"
+	AssumeRoleCredentialSource      = "assume_role"
+)
+
+type S3AccessKey struct {
+	Id     string
+	Secret string
+}
+
+// S3Credentials describes where a bucket gets its credentials from. An empty
+// Source means static credentials. When assuming a role, the AccessKey (or the
+// instance profile, if no AccessKey is given) is used to call STS.
+type S3Credentials struct {
+	Source     string
+	AccessKey  S3AccessKey
+	RoleARN    string
+	ExternalId string
+}
+
+func newCredentials(regionName string, s3Credentials S3Credentials) (*credentials.Credentials, error) {
+	switch s3Credentials.Source {
+	case "", StaticCredentialSource:
+		return staticCredentials(s3Credentials.AccessKey), nil
+	case InstanceProfileCredentialSource:
+		return instanceProfileCredentials(regionName)
+	case AssumeRoleCredentialSource:
+		return assumeRoleCredentials(regionName, s3Credentials)
+	}
+
+	return nil, fmt.Errorf("unsupported credential source '%s'", s3Credentials.Source)
+}
+
+func staticCredentials(accessKey S3AccessKey) *credentials.Credentials {
+	return credentials.NewStaticCredentials(accessKey.Id, accessKey.Secret, "")
+}
+
+func instanceProfileCredentials(regionName string) (*credentials.Credentials, error) {
+	metadataSession, err := session.NewSession(&aws.Config{Region: aws.String(regionName)})
+	if err != nil {
+		return nil, fmt.Errorf("could not create an EC2 metadata session: %s", err.Error())
+	}
+
+	return ec2rolecreds.NewCredentials(metadataSession), nil
+}
+
+func assumeRoleCredentials(regionName string, s3Credentials S3Credentials) (*credentials.Credentials, error) {
+	if s3Credentials.RoleARN == "" {
+		return nil, errors.New("missing role ARN for the assume_role credential source")
+	}
+
+	var baseCredentials *credentials.Credentials
+	if s3Credentials.AccessKey != (S3AccessKey{}) {
+		baseCredentials = staticCredentials(s3Credentials.AccessKey)
+	} else {
+		var err error
+		baseCredentials, err = instanceProfileCredentials(regionName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	stsSession, err := session.NewSession(&aws.Config{
+		Region:      aws.String(regionName),
+		Credentials: baseCredentials,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not create an STS session: %s", err.Error())
+	}
+
+	return stscreds.NewCredentials(stsSession, s3Credentials.RoleARN, func(provider *stscreds.AssumeRoleProvider) {
+		if s3Credentials.ExternalId != "" {
+			provider.ExternalID = aws.String(s3Credentials.ExternalId)
+		}
+	}), nil
+}
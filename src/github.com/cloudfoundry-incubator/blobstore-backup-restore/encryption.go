@@ -0,0 +1,96 @@
+package blobstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// An encrypted artifact is encryptedMagic, the format version, a random salt
+// and nonce, and the artifact sealed with AES-256-GCM, using a key derived from
+// the encryption key with PBKDF2. The magic and the format version are sealed
+// with it.
+var encryptedMagic = []byte("BBRBSENC")
+
+const (
+	encryptionFormatVersion = 1
+	encryptionSaltSize      = 16
+	encryptionKeyIterations = 100000
+)
+
+func isEncrypted(contents []byte) bool {
+	return bytes.HasPrefix(contents, encryptedMagic)
+}
+
+func encrypt(plaintext []byte, encryptionKey string) ([]byte, error) {
+	salt := make([]byte, encryptionSaltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(encryptionKey, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, encryptedMagic...), encryptionFormatVersion)
+	encrypted := append(append(append([]byte{}, header...), salt...), nonce...)
+	return aead.Seal(encrypted, nonce, plaintext, header), nil
+}
+
+func decrypt(encrypted []byte, encryptionKey string) ([]byte, error) {
+	if !isEncrypted(encrypted) {
+		return nil, errors.New("backup file is not encrypted")
+	}
+
+	headerSize := len(encryptedMagic) + 1
+	if len(encrypted) < headerSize+encryptionSaltSize {
+		return nil, errors.New("backup file is truncated")
+	}
+
+	header := encrypted[:headerSize]
+	if version := header[len(encryptedMagic)]; version != encryptionFormatVersion {
+		return nil, fmt.Errorf("backup file is encrypted with unsupported format version %d", version)
+	}
+
+	aead, err := newAEAD(encryptionKey, encrypted[headerSize:headerSize+encryptionSaltSize])
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := encrypted[headerSize+encryptionSaltSize:]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("backup file is truncated")
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], header)
+	if err != nil {
+		return nil, errors.New("could not decrypt backup file: the encryption key is wrong or the file is corrupt")
+	}
+
+	return plaintext, nil
+}
+
+func newAEAD(encryptionKey string, salt []byte) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(encryptionKey), salt, encryptionKeyIterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
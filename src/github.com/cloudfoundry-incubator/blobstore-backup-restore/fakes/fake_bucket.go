@@ -26,6 +26,15 @@ type FakeBucket struct {
 	regionNameReturnsOnCall map[int]struct {
 		result1 string
 	}
+	EndpointStub        func() string
+	endpointMutex       sync.RWMutex
+	endpointArgsForCall []struct{}
+	endpointReturns     struct {
+		result1 string
+	}
+	endpointReturnsOnCall map[int]struct {
+		result1 string
+	}
 	VersionsStub        func() ([]blobstore.Version, error)
 	versionsMutex       sync.RWMutex
 	versionsArgsForCall []struct{}
@@ -37,17 +46,65 @@ type FakeBucket struct {
 		result1 []blobstore.Version
 		result2 error
 	}
-	PutVersionsStub        func(regionName, bucketName string, versions []blobstore.LatestVersion) error
-	putVersionsMutex       sync.RWMutex
-	putVersionsArgsForCall []struct {
+	CheckVersioningStub        func() error
+	checkVersioningMutex       sync.RWMutex
+	checkVersioningArgsForCall []struct{}
+	checkVersioningReturns     struct {
+		result1 error
+	}
+	checkVersioningReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CopyVersionsStub        func(regionName, bucketName string, versions []blobstore.LatestVersion) error
+	copyVersionsMutex       sync.RWMutex
+	copyVersionsArgsForCall []struct {
 		regionName string
 		bucketName string
 		versions   []blobstore.LatestVersion
 	}
-	putVersionsReturns struct {
+	copyVersionsReturns struct {
 		result1 error
 	}
-	putVersionsReturnsOnCall map[int]struct {
+	copyVersionsReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CopyVersionsFromPrefixStub        func(regionName, bucketName, prefix string, versions []blobstore.LatestVersion) error
+	copyVersionsFromPrefixMutex       sync.RWMutex
+	copyVersionsFromPrefixArgsForCall []struct {
+		regionName string
+		bucketName string
+		prefix     string
+		versions   []blobstore.LatestVersion
+	}
+	copyVersionsFromPrefixReturns struct {
+		result1 error
+	}
+	copyVersionsFromPrefixReturnsOnCall map[int]struct {
+		result1 error
+	}
+	CopyVersionsToPrefixStub        func(regionName, bucketName, prefix string, versions []blobstore.LatestVersion) error
+	copyVersionsToPrefixMutex       sync.RWMutex
+	copyVersionsToPrefixArgsForCall []struct {
+		regionName string
+		bucketName string
+		prefix     string
+		versions   []blobstore.LatestVersion
+	}
+	copyVersionsToPrefixReturns struct {
+		result1 error
+	}
+	copyVersionsToPrefixReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteFilesStub        func(keys []string) error
+	deleteFilesMutex       sync.RWMutex
+	deleteFilesArgsForCall []struct {
+		keys []string
+	}
+	deleteFilesReturns struct {
+		result1 error
+	}
+	deleteFilesReturnsOnCall map[int]struct {
 		result1 error
 	}
 	invocations      map[string][][]interface{}
@@ -134,6 +191,46 @@ func (fake *FakeBucket) RegionNameReturnsOnCall(i int, result1 string) {
 	}{result1}
 }
 
+func (fake *FakeBucket) Endpoint() string {
+	fake.endpointMutex.Lock()
+	ret, specificReturn := fake.endpointReturnsOnCall[len(fake.endpointArgsForCall)]
+	fake.endpointArgsForCall = append(fake.endpointArgsForCall, struct{}{})
+	fake.recordInvocation("Endpoint", []interface{}{})
+	fake.endpointMutex.Unlock()
+	if fake.EndpointStub != nil {
+		return fake.EndpointStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.endpointReturns.result1
+}
+
+func (fake *FakeBucket) EndpointCallCount() int {
+	fake.endpointMutex.RLock()
+	defer fake.endpointMutex.RUnlock()
+	return len(fake.endpointArgsForCall)
+}
+
+func (fake *FakeBucket) EndpointReturns(result1 string) {
+	fake.EndpointStub = nil
+	fake.endpointReturns = struct {
+		result1 string
+	}{result1}
+}
+
+func (fake *FakeBucket) EndpointReturnsOnCall(i int, result1 string) {
+	fake.EndpointStub = nil
+	if fake.endpointReturnsOnCall == nil {
+		fake.endpointReturnsOnCall = make(map[int]struct {
+			result1 string
+		})
+	}
+	fake.endpointReturnsOnCall[i] = struct {
+		result1 string
+	}{result1}
+}
+
 func (fake *FakeBucket) Versions() ([]blobstore.Version, error) {
 	fake.versionsMutex.Lock()
 	ret, specificReturn := fake.versionsReturnsOnCall[len(fake.versionsArgsForCall)]
@@ -177,57 +274,262 @@ func (fake *FakeBucket) VersionsReturnsOnCall(i int, result1 []blobstore.Version
 	}{result1, result2}
 }
 
-func (fake *FakeBucket) PutVersions(regionName string, bucketName string, versions []blobstore.LatestVersion) error {
+func (fake *FakeBucket) CheckVersioning() error {
+	fake.checkVersioningMutex.Lock()
+	ret, specificReturn := fake.checkVersioningReturnsOnCall[len(fake.checkVersioningArgsForCall)]
+	fake.checkVersioningArgsForCall = append(fake.checkVersioningArgsForCall, struct{}{})
+	fake.recordInvocation("CheckVersioning", []interface{}{})
+	fake.checkVersioningMutex.Unlock()
+	if fake.CheckVersioningStub != nil {
+		return fake.CheckVersioningStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.checkVersioningReturns.result1
+}
+
+func (fake *FakeBucket) CheckVersioningCallCount() int {
+	fake.checkVersioningMutex.RLock()
+	defer fake.checkVersioningMutex.RUnlock()
+	return len(fake.checkVersioningArgsForCall)
+}
+
+func (fake *FakeBucket) CheckVersioningReturns(result1 error) {
+	fake.CheckVersioningStub = nil
+	fake.checkVersioningReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) CheckVersioningReturnsOnCall(i int, result1 error) {
+	fake.CheckVersioningStub = nil
+	if fake.checkVersioningReturnsOnCall == nil {
+		fake.checkVersioningReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.checkVersioningReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) CopyVersions(regionName string, bucketName string, versions []blobstore.LatestVersion) error {
 	var versionsCopy []blobstore.LatestVersion
 	if versions != nil {
 		versionsCopy = make([]blobstore.LatestVersion, len(versions))
 		copy(versionsCopy, versions)
 	}
-	fake.putVersionsMutex.Lock()
-	ret, specificReturn := fake.putVersionsReturnsOnCall[len(fake.putVersionsArgsForCall)]
-	fake.putVersionsArgsForCall = append(fake.putVersionsArgsForCall, struct {
+	fake.copyVersionsMutex.Lock()
+	ret, specificReturn := fake.copyVersionsReturnsOnCall[len(fake.copyVersionsArgsForCall)]
+	fake.copyVersionsArgsForCall = append(fake.copyVersionsArgsForCall, struct {
 		regionName string
 		bucketName string
 		versions   []blobstore.LatestVersion
 	}{regionName, bucketName, versionsCopy})
-	fake.recordInvocation("PutVersions", []interface{}{regionName, bucketName, versionsCopy})
-	fake.putVersionsMutex.Unlock()
-	if fake.PutVersionsStub != nil {
-		return fake.PutVersionsStub(regionName, bucketName, versions)
+	fake.recordInvocation("CopyVersions", []interface{}{regionName, bucketName, versionsCopy})
+	fake.copyVersionsMutex.Unlock()
+	if fake.CopyVersionsStub != nil {
+		return fake.CopyVersionsStub(regionName, bucketName, versions)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.copyVersionsReturns.result1
+}
+
+func (fake *FakeBucket) CopyVersionsCallCount() int {
+	fake.copyVersionsMutex.RLock()
+	defer fake.copyVersionsMutex.RUnlock()
+	return len(fake.copyVersionsArgsForCall)
+}
+
+func (fake *FakeBucket) CopyVersionsArgsForCall(i int) (string, string, []blobstore.LatestVersion) {
+	fake.copyVersionsMutex.RLock()
+	defer fake.copyVersionsMutex.RUnlock()
+	return fake.copyVersionsArgsForCall[i].regionName, fake.copyVersionsArgsForCall[i].bucketName, fake.copyVersionsArgsForCall[i].versions
+}
+
+func (fake *FakeBucket) CopyVersionsReturns(result1 error) {
+	fake.CopyVersionsStub = nil
+	fake.copyVersionsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) CopyVersionsReturnsOnCall(i int, result1 error) {
+	fake.CopyVersionsStub = nil
+	if fake.copyVersionsReturnsOnCall == nil {
+		fake.copyVersionsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.copyVersionsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) CopyVersionsFromPrefix(regionName string, bucketName string, prefix string, versions []blobstore.LatestVersion) error {
+	var versionsCopy []blobstore.LatestVersion
+	if versions != nil {
+		versionsCopy = make([]blobstore.LatestVersion, len(versions))
+		copy(versionsCopy, versions)
+	}
+	fake.copyVersionsFromPrefixMutex.Lock()
+	ret, specificReturn := fake.copyVersionsFromPrefixReturnsOnCall[len(fake.copyVersionsFromPrefixArgsForCall)]
+	fake.copyVersionsFromPrefixArgsForCall = append(fake.copyVersionsFromPrefixArgsForCall, struct {
+		regionName string
+		bucketName string
+		prefix     string
+		versions   []blobstore.LatestVersion
+	}{regionName, bucketName, prefix, versionsCopy})
+	fake.recordInvocation("CopyVersionsFromPrefix", []interface{}{regionName, bucketName, prefix, versionsCopy})
+	fake.copyVersionsFromPrefixMutex.Unlock()
+	if fake.CopyVersionsFromPrefixStub != nil {
+		return fake.CopyVersionsFromPrefixStub(regionName, bucketName, prefix, versions)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.copyVersionsFromPrefixReturns.result1
+}
+
+func (fake *FakeBucket) CopyVersionsFromPrefixCallCount() int {
+	fake.copyVersionsFromPrefixMutex.RLock()
+	defer fake.copyVersionsFromPrefixMutex.RUnlock()
+	return len(fake.copyVersionsFromPrefixArgsForCall)
+}
+
+func (fake *FakeBucket) CopyVersionsFromPrefixArgsForCall(i int) (string, string, string, []blobstore.LatestVersion) {
+	fake.copyVersionsFromPrefixMutex.RLock()
+	defer fake.copyVersionsFromPrefixMutex.RUnlock()
+	return fake.copyVersionsFromPrefixArgsForCall[i].regionName, fake.copyVersionsFromPrefixArgsForCall[i].bucketName, fake.copyVersionsFromPrefixArgsForCall[i].prefix, fake.copyVersionsFromPrefixArgsForCall[i].versions
+}
+
+func (fake *FakeBucket) CopyVersionsFromPrefixReturns(result1 error) {
+	fake.CopyVersionsFromPrefixStub = nil
+	fake.copyVersionsFromPrefixReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) CopyVersionsFromPrefixReturnsOnCall(i int, result1 error) {
+	fake.CopyVersionsFromPrefixStub = nil
+	if fake.copyVersionsFromPrefixReturnsOnCall == nil {
+		fake.copyVersionsFromPrefixReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.copyVersionsFromPrefixReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) CopyVersionsToPrefix(regionName string, bucketName string, prefix string, versions []blobstore.LatestVersion) error {
+	var versionsCopy []blobstore.LatestVersion
+	if versions != nil {
+		versionsCopy = make([]blobstore.LatestVersion, len(versions))
+		copy(versionsCopy, versions)
+	}
+	fake.copyVersionsToPrefixMutex.Lock()
+	ret, specificReturn := fake.copyVersionsToPrefixReturnsOnCall[len(fake.copyVersionsToPrefixArgsForCall)]
+	fake.copyVersionsToPrefixArgsForCall = append(fake.copyVersionsToPrefixArgsForCall, struct {
+		regionName string
+		bucketName string
+		prefix     string
+		versions   []blobstore.LatestVersion
+	}{regionName, bucketName, prefix, versionsCopy})
+	fake.recordInvocation("CopyVersionsToPrefix", []interface{}{regionName, bucketName, prefix, versionsCopy})
+	fake.copyVersionsToPrefixMutex.Unlock()
+	if fake.CopyVersionsToPrefixStub != nil {
+		return fake.CopyVersionsToPrefixStub(regionName, bucketName, prefix, versions)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.copyVersionsToPrefixReturns.result1
+}
+
+func (fake *FakeBucket) CopyVersionsToPrefixCallCount() int {
+	fake.copyVersionsToPrefixMutex.RLock()
+	defer fake.copyVersionsToPrefixMutex.RUnlock()
+	return len(fake.copyVersionsToPrefixArgsForCall)
+}
+
+func (fake *FakeBucket) CopyVersionsToPrefixArgsForCall(i int) (string, string, string, []blobstore.LatestVersion) {
+	fake.copyVersionsToPrefixMutex.RLock()
+	defer fake.copyVersionsToPrefixMutex.RUnlock()
+	return fake.copyVersionsToPrefixArgsForCall[i].regionName, fake.copyVersionsToPrefixArgsForCall[i].bucketName, fake.copyVersionsToPrefixArgsForCall[i].prefix, fake.copyVersionsToPrefixArgsForCall[i].versions
+}
+
+func (fake *FakeBucket) CopyVersionsToPrefixReturns(result1 error) {
+	fake.CopyVersionsToPrefixStub = nil
+	fake.copyVersionsToPrefixReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) CopyVersionsToPrefixReturnsOnCall(i int, result1 error) {
+	fake.CopyVersionsToPrefixStub = nil
+	if fake.copyVersionsToPrefixReturnsOnCall == nil {
+		fake.copyVersionsToPrefixReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.copyVersionsToPrefixReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBucket) DeleteFiles(keys []string) error {
+	var keysCopy []string
+	if keys != nil {
+		keysCopy = make([]string, len(keys))
+		copy(keysCopy, keys)
+	}
+	fake.deleteFilesMutex.Lock()
+	ret, specificReturn := fake.deleteFilesReturnsOnCall[len(fake.deleteFilesArgsForCall)]
+	fake.deleteFilesArgsForCall = append(fake.deleteFilesArgsForCall, struct {
+		keys []string
+	}{keysCopy})
+	fake.recordInvocation("DeleteFiles", []interface{}{keysCopy})
+	fake.deleteFilesMutex.Unlock()
+	if fake.DeleteFilesStub != nil {
+		return fake.DeleteFilesStub(keys)
 	}
 	if specificReturn {
 		return ret.result1
 	}
-	return fake.putVersionsReturns.result1
+	return fake.deleteFilesReturns.result1
 }
 
-func (fake *FakeBucket) PutVersionsCallCount() int {
-	fake.putVersionsMutex.RLock()
-	defer fake.putVersionsMutex.RUnlock()
-	return len(fake.putVersionsArgsForCall)
+func (fake *FakeBucket) DeleteFilesCallCount() int {
+	fake.deleteFilesMutex.RLock()
+	defer fake.deleteFilesMutex.RUnlock()
+	return len(fake.deleteFilesArgsForCall)
 }
 
-func (fake *FakeBucket) PutVersionsArgsForCall(i int) (string, string, []blobstore.LatestVersion) {
-	fake.putVersionsMutex.RLock()
-	defer fake.putVersionsMutex.RUnlock()
-	return fake.putVersionsArgsForCall[i].regionName, fake.putVersionsArgsForCall[i].bucketName, fake.putVersionsArgsForCall[i].versions
+func (fake *FakeBucket) DeleteFilesArgsForCall(i int) []string {
+	fake.deleteFilesMutex.RLock()
+	defer fake.deleteFilesMutex.RUnlock()
+	return fake.deleteFilesArgsForCall[i].keys
 }
 
-func (fake *FakeBucket) PutVersionsReturns(result1 error) {
-	fake.PutVersionsStub = nil
-	fake.putVersionsReturns = struct {
+func (fake *FakeBucket) DeleteFilesReturns(result1 error) {
+	fake.DeleteFilesStub = nil
+	fake.deleteFilesReturns = struct {
 		result1 error
 	}{result1}
 }
 
-func (fake *FakeBucket) PutVersionsReturnsOnCall(i int, result1 error) {
-	fake.PutVersionsStub = nil
-	if fake.putVersionsReturnsOnCall == nil {
-		fake.putVersionsReturnsOnCall = make(map[int]struct {
+func (fake *FakeBucket) DeleteFilesReturnsOnCall(i int, result1 error) {
+	fake.DeleteFilesStub = nil
+	if fake.deleteFilesReturnsOnCall == nil {
+		fake.deleteFilesReturnsOnCall = make(map[int]struct {
 			result1 error
 		})
 	}
-	fake.putVersionsReturnsOnCall[i] = struct {
+	fake.deleteFilesReturnsOnCall[i] = struct {
 		result1 error
 	}{result1}
 }
@@ -239,10 +541,20 @@ func (fake *FakeBucket) Invocations() map[string][][]interface{} {
 	defer fake.nameMutex.RUnlock()
 	fake.regionNameMutex.RLock()
 	defer fake.regionNameMutex.RUnlock()
+	fake.endpointMutex.RLock()
+	defer fake.endpointMutex.RUnlock()
 	fake.versionsMutex.RLock()
 	defer fake.versionsMutex.RUnlock()
-	fake.putVersionsMutex.RLock()
-	defer fake.putVersionsMutex.RUnlock()
+	fake.checkVersioningMutex.RLock()
+	defer fake.checkVersioningMutex.RUnlock()
+	fake.copyVersionsMutex.RLock()
+	defer fake.copyVersionsMutex.RUnlock()
+	fake.copyVersionsFromPrefixMutex.RLock()
+	defer fake.copyVersionsFromPrefixMutex.RUnlock()
+	fake.copyVersionsToPrefixMutex.RLock()
+	defer fake.copyVersionsToPrefixMutex.RUnlock()
+	fake.deleteFilesMutex.RLock()
+	defer fake.deleteFilesMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,54 @@
+package blobstore
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const defaultWorkers = 10
+
+func executeInParallel(workers, count int, action func(index int) error) []error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	errs := []error{}
+	errsMutex := sync.Mutex{}
+	waitGroup := sync.WaitGroup{}
+
+	for worker := 0; worker < workers; worker++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+
+			for index := range indexes {
+				err := action(index)
+				if err != nil {
+					errsMutex.Lock()
+					errs = append(errs, err)
+					errsMutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for index := 0; index < count; index++ {
+		indexes <- index
+	}
+	close(indexes)
+
+	waitGroup.Wait()
+
+	return errs
+}
+
+func formatErrors(contextMessage string, errs []error) error {
+	errorMessages := []string{}
+	for _, err := range errs {
+		errorMessages = append(errorMessages, err.Error())
+	}
+
+	return fmt.Errorf("%s (%d error(s)):\n%s", contextMessage, len(errs), strings.Join(errorMessages, "\n"))
+}
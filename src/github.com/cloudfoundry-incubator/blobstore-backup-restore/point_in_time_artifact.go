@@ -0,0 +1,84 @@
+package blobstore
+
+import (
+	"errors"
+	"time"
+)
+
+// PointInTimeArtifact reconstructs a backup of every bucket from its version
+// history, as it was at a given time, so that a bucket can be restored
+// without an artifact from a previous backup.
+type PointInTimeArtifact struct {
+	buckets map[string]Bucket
+	asOf    time.Time
+}
+
+func NewPointInTimeArtifact(buckets map[string]Bucket, asOf time.Time) PointInTimeArtifact {
+	return PointInTimeArtifact{buckets: buckets, asOf: asOf}
+}
+
+func (a PointInTimeArtifact) Save(backup map[string]BucketBackup) error {
+	return errors.New("a point in time artifact cannot be saved")
+}
+
+func (a PointInTimeArtifact) Load() (map[string]BucketBackup, error) {
+	backup := map[string]BucketBackup{}
+	for identifier, bucket := range a.buckets {
+		versions, err := bucket.Versions()
+		if err != nil {
+			return nil, err
+		}
+
+		backup[identifier] = BucketBackup{
+			BucketName: bucket.Name(),
+			RegionName: bucket.RegionName(),
+			Versions:   filterLatestAsOf(versions, a.asOf),
+		}
+	}
+
+	return backup, nil
+}
+
+// filterLatestAsOf finds the version of every file that was the latest at the
+// given time. Files that did not exist yet, or whose latest version was a delete
+// marker, are left out.
+func filterLatestAsOf(versions []Version, asOf time.Time) []LatestVersion {
+	latestVersions := map[string]Version{}
+	keys := []string{}
+	for _, version := range versions {
+		if version.LastModified.After(asOf) {
+			continue
+		}
+
+		latestVersion, exists := latestVersions[version.Key]
+		if !exists {
+			keys = append(keys, version.Key)
+		}
+		if !exists || isNewer(version, latestVersion) {
+			latestVersions[version.Key] = version
+		}
+	}
+
+	filteredVersions := []LatestVersion{}
+	for _, key := range keys {
+		version := latestVersions[key]
+		if !version.IsDeleteMarker {
+			filteredVersions = append(filteredVersions, LatestVersion{BlobKey: version.Key, Id: version.Id})
+		}
+	}
+	return filteredVersions
+}
+
+// isNewer orders two versions of a file. S3 only records LastModified to the
+// second, and lists versions before delete markers, so when two versions share
+// it the one S3 marks as the latest wins, and otherwise the delete marker does,
+// so that a deleted file is not brought back.
+func isNewer(version, otherVersion Version) bool {
+	if !version.LastModified.Equal(otherVersion.LastModified) {
+		return version.LastModified.After(otherVersion.LastModified)
+	}
+	if version.IsLatest != otherVersion.IsLatest {
+		return version.IsLatest
+	}
+	return version.IsDeleteMarker && !otherVersion.IsDeleteMarker
+}
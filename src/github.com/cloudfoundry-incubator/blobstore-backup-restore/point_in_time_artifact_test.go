@@ -0,0 +1,103 @@
+package blobstore_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/cloudfoundry-incubator/blobstore-backup-restore"
+	"github.com/cloudfoundry-incubator/blobstore-backup-restore/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PointInTimeArtifact", func() {
+	var dropletsBucket *fakes.FakeBucket
+	var asOf time.Time
+	var artifact PointInTimeArtifact
+
+	BeforeEach(func() {
+		dropletsBucket = new(fakes.FakeBucket)
+		dropletsBucket.NameReturns("my_droplets_bucket")
+		dropletsBucket.RegionNameReturns("my_droplets_region")
+
+		asOf = time.Date(2017, 11, 20, 12, 0, 0, 0, time.UTC)
+		artifact = NewPointInTimeArtifact(map[string]Bucket{"droplets": dropletsBucket}, asOf)
+	})
+
+	Describe("Load", func() {
+		Context("when listing the versions succeeds", func() {
+			BeforeEach(func() {
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "12", IsLatest: true, LastModified: asOf.Add(time.Hour)},
+					{Key: "one", Id: "11", LastModified: asOf.Add(-time.Hour)},
+					{Key: "two", Id: "21", IsLatest: true, LastModified: asOf},
+					{Key: "three", Id: "31", IsLatest: true, LastModified: asOf.Add(time.Minute)},
+					{Key: "four", Id: "41", LastModified: asOf.Add(-2 * time.Hour)},
+					{Key: "four", Id: "42", IsLatest: true, IsDeleteMarker: true, LastModified: asOf.Add(-time.Hour)},
+					{Key: "five", Id: "51", IsLatest: true, LastModified: asOf.Add(-2 * time.Hour)},
+					{Key: "five", Id: "52", IsDeleteMarker: true, LastModified: asOf.Add(time.Hour)},
+				}, nil)
+			})
+
+			It("returns the versions that were the latest at that time", func() {
+				backup, err := artifact.Load()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(backup).To(Equal(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "11"},
+							{BlobKey: "two", Id: "21"},
+							{BlobKey: "five", Id: "51"},
+						},
+					},
+				}))
+			})
+		})
+
+		Context("when a version and a delete marker of a file were made in the same second", func() {
+			BeforeEach(func() {
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "11", LastModified: asOf.Add(-time.Hour)},
+					{Key: "two", Id: "21", IsLatest: true, LastModified: asOf.Add(-time.Hour)},
+					{Key: "one", Id: "12", IsLatest: true, IsDeleteMarker: true, LastModified: asOf.Add(-time.Hour)},
+					{Key: "two", Id: "22", IsDeleteMarker: true, LastModified: asOf.Add(-time.Hour)},
+					{Key: "three", Id: "31", LastModified: asOf.Add(-time.Hour)},
+					{Key: "three", Id: "32", IsDeleteMarker: true, LastModified: asOf.Add(-time.Hour)},
+					{Key: "three", Id: "33", IsLatest: true, LastModified: asOf.Add(time.Hour)},
+				}, nil)
+			})
+
+			It("uses the one S3 marks as the latest, and otherwise the delete marker", func() {
+				backup, err := artifact.Load()
+
+				Expect(err).NotTo(HaveOccurred())
+				Expect(backup["droplets"].Versions).To(Equal([]LatestVersion{
+					{BlobKey: "two", Id: "21"},
+				}))
+			})
+		})
+
+		Context("when listing the versions fails", func() {
+			BeforeEach(func() {
+				dropletsBucket.VersionsReturns(nil, errors.New("failed to list versions"))
+			})
+
+			It("returns the error", func() {
+				backup, err := artifact.Load()
+
+				Expect(backup).To(BeNil())
+				Expect(err).To(MatchError("failed to list versions"))
+			})
+		})
+	})
+
+	Describe("Save", func() {
+		It("returns an error", func() {
+			Expect(artifact.Save(map[string]BucketBackup{})).To(MatchError("a point in time artifact cannot be saved"))
+		})
+	})
+})
@@ -1,23 +1,370 @@
 package blobstore
 
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
 type Restorer struct {
 	buckets  map[string]Bucket
 	artifact Artifact
+	options  RestoreOptions
+}
+
+// RestoreOptions change which buckets a restore changes and how. By default
+// the buckets configured and the buckets in the artifact must match exactly;
+// with SkipUnmatchedBuckets only the buckets in both are restored.
+//
+// Buckets, when not empty, restricts the restore to the buckets with those
+// identifiers, and Prefix restricts it to the files whose keys start with it.
+// Files outside of them are neither copied nor deleted.
+type RestoreOptions struct {
+	DeleteThreshold      DeleteThreshold
+	SkipUnmatchedBuckets bool
+	Buckets              []string
+	Prefix               string
+}
+
+// DeleteThreshold stops a restore that would delete more of a bucket's live
+// files than expected, e.g. because the wrong artifact or bucket was given.
+// A zero MaxCount or MaxPercentage means no limit, and is the default.
+type DeleteThreshold struct {
+	MaxCount      int
+	MaxPercentage int
+}
+
+// RestoreSummary counts the files of a bucket by what the restore did to them.
+// Undeleted files had been deleted since the backup and were copied back.
+type RestoreSummary struct {
+	Unchanged int
+	Copied    int
+	Undeleted int
+	Deleted   int
+}
+
+func NewRestorer(buckets map[string]Bucket, artifact Artifact, options RestoreOptions) Restorer {
+	return Restorer{buckets: buckets, artifact: artifact, options: options}
 }
 
-func NewRestorer(buckets map[string]Bucket, artifact Artifact) Restorer {
-	return Restorer{buckets: buckets, artifact: artifact}
+// RestorePlan lists the files of a bucket by what a restore would do to them.
+// ExceedsDeleteThreshold is true when the restore would be refused because it
+// deletes too many of them.
+type RestorePlan struct {
+	Unchanged              []string `json:"unchanged"`
+	Copied                 []string `json:"copied"`
+	Undeleted              []string `json:"undeleted"`
+	Deleted                []string `json:"deleted"`
+	ExceedsDeleteThreshold bool     `json:"exceeds_delete_threshold"`
 }
 
-func (r Restorer) Restore() error {
+func (p RestorePlan) Summary() RestoreSummary {
+	return RestoreSummary{
+		Unchanged: len(p.Unchanged),
+		Copied:    len(p.Copied),
+		Undeleted: len(p.Undeleted),
+		Deleted:   len(p.Deleted),
+	}
+}
+
+type bucketRestore struct {
+	bucket         Bucket
+	bucketBackup   BucketBackup
+	versionsToCopy []LatestVersion
+	liveFileCount  int
+	plan           RestorePlan
+}
+
+// Plan works out what Restore would do to every bucket, without changing any
+// of them.
+func (r Restorer) Plan() (map[string]RestorePlan, error) {
+	restores, err := r.planRestores()
+	if err != nil {
+		return nil, err
+	}
+
+	plans := map[string]RestorePlan{}
+	for identifier, restore := range restores {
+		restore.plan.ExceedsDeleteThreshold = r.options.DeleteThreshold.isExceededBy(restore)
+		plans[identifier] = restore.plan
+	}
+
+	return plans, nil
+}
+
+func (r Restorer) Restore() (map[string]RestoreSummary, error) {
+	restores, err := r.planRestores()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.checkDeleteThreshold(restores)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := map[string]RestoreSummary{}
+	for identifier, restore := range restores {
+		err := restore.execute()
+		if err != nil {
+			return nil, err
+		}
+
+		summaries[identifier] = restore.plan.Summary()
+	}
+
+	return summaries, nil
+}
+
+func (r Restorer) planRestores() (map[string]bucketRestore, error) {
 	backup, err := r.artifact.Load()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	buckets, backup, err := r.selectBuckets(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.checkBucketsMatch(buckets, backup)
+	if err != nil {
+		return nil, err
+	}
+
+	restores := map[string]bucketRestore{}
+	for identifier, bucket := range buckets {
+		bucketBackup, isBackedUp := backup[identifier]
+		if !isBackedUp {
+			continue
+		}
+
+		restore, err := planBucketRestore(bucket, bucketBackup, r.options.Prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		restores[identifier] = restore
+	}
+
+	return restores, nil
+}
+
+func (r Restorer) selectBuckets(backup map[string]BucketBackup) (map[string]Bucket, map[string]BucketBackup, error) {
+	if len(r.options.Buckets) == 0 {
+		return r.buckets, backup, nil
+	}
+
+	selectedBuckets := map[string]Bucket{}
+	selectedBackup := map[string]BucketBackup{}
+	for _, identifier := range r.options.Buckets {
+		bucket, isConfigured := r.buckets[identifier]
+		if !isConfigured {
+			return nil, nil, fmt.Errorf("cannot restore bucket '%s' as it is not configured", identifier)
+		}
+		selectedBuckets[identifier] = bucket
+
+		if bucketBackup, isBackedUp := backup[identifier]; isBackedUp {
+			selectedBackup[identifier] = bucketBackup
+		}
+	}
+
+	return selectedBuckets, selectedBackup, nil
+}
+
+// checkBucketsMatch stops a bucket missing from the artifact from being
+// restored to an empty bucket.
+func (r Restorer) checkBucketsMatch(buckets map[string]Bucket, backup map[string]BucketBackup) error {
+	errs := []error{}
+	for _, identifier := range sortedIdentifiers(buckets) {
+		if _, isBackedUp := backup[identifier]; !isBackedUp {
+			errs = append(errs, fmt.Errorf("bucket '%s' is configured but is not in the artifact", identifier))
+		}
+	}
+
+	backedUpIdentifiers := []string{}
+	for identifier := range backup {
+		backedUpIdentifiers = append(backedUpIdentifiers, identifier)
+	}
+	sort.Strings(backedUpIdentifiers)
+
+	for _, identifier := range backedUpIdentifiers {
+		if _, isConfigured := buckets[identifier]; !isConfigured {
+			errs = append(errs, fmt.Errorf("bucket '%s' is in the artifact but is not configured", identifier))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	if r.options.SkipUnmatchedBuckets {
+		for _, err := range errs {
+			log.Printf("skipping %s\n", err.Error())
+		}
+		return nil
+	}
+
+	return formatErrors("the configured buckets do not match the buckets in the artifact", errs)
+}
+
+func (r Restorer) checkDeleteThreshold(restores map[string]bucketRestore) error {
+	identifiers := []string{}
+	for identifier := range restores {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+
+	errs := []error{}
+	for _, identifier := range identifiers {
+		restore := restores[identifier]
+		if r.options.DeleteThreshold.isExceededBy(restore) {
+			deleteCount := len(restore.plan.Deleted)
+			errs = append(errs, fmt.Errorf("bucket '%s' would have %d of its %d files (%.1f%%) deleted",
+				identifier, deleteCount, restore.liveFileCount, float64(deleteCount)*100/float64(restore.liveFileCount)))
+		}
+	}
+
+	if len(errs) != 0 {
+		return formatErrors("restore would delete "+r.options.DeleteThreshold.describe()+" of a bucket", errs)
+	}
+
+	return nil
+}
+
+func (t DeleteThreshold) isExceededBy(restore bucketRestore) bool {
+	deleteCount := len(restore.plan.Deleted)
+	if deleteCount == 0 {
+		return false
+	}
+
+	isOverMaxCount := t.MaxCount != 0 && deleteCount > t.MaxCount
+	isOverMaxPercentage := t.MaxPercentage != 0 && deleteCount*100 > t.MaxPercentage*restore.liveFileCount
+	return isOverMaxCount || isOverMaxPercentage
+}
+
+func (t DeleteThreshold) describe() string {
+	limits := []string{}
+	if t.MaxCount != 0 {
+		limits = append(limits, fmt.Sprintf("%d files", t.MaxCount))
+	}
+	if t.MaxPercentage != 0 {
+		limits = append(limits, fmt.Sprintf("%d%% of the files", t.MaxPercentage))
+	}
+	return "more than " + strings.Join(limits, " or ")
+}
+
+func planBucketRestore(bucket Bucket, bucketBackup BucketBackup, prefix string) (bucketRestore, error) {
+	// versions can only be copied between buckets on the same endpoint, and
+	// another endpoint can have a bucket with the same name
+	if bucketBackup.Endpoint != bucket.Endpoint() {
+		return bucketRestore{}, fmt.Errorf("cannot restore bucket '%s' from bucket '%s' on %s",
+			bucket.Name(), bucketBackup.BucketName, describeEndpoint(bucketBackup.Endpoint))
+	}
+
+	versions, err := bucket.Versions()
+	if err != nil {
+		return bucketRestore{}, err
+	}
+
+	liveVersions := map[string]string{}
+	deletedFiles := map[string]bool{}
+	retainedVersions := map[LatestVersion]bool{}
+	for _, version := range versions {
+		if !strings.HasPrefix(version.Key, prefix) {
+			continue
+		}
+
+		if version.IsDeleteMarker {
+			deletedFiles[version.Key] = deletedFiles[version.Key] || version.IsLatest
+			continue
+		}
+
+		if version.IsLatest {
+			liveVersions[version.Key] = version.Id
+		}
+		retainedVersions[LatestVersion{BlobKey: version.Key, Id: version.Id}] = true
+	}
+
+	// a version id only identifies the same blob within the bucket it was backed up from
+	isSameBucket := bucketBackup.Prefix == "" && bucketBackup.BucketName == bucket.Name()
+
+	restore := bucketRestore{
+		bucket:         bucket,
+		bucketBackup:   bucketBackup,
+		versionsToCopy: []LatestVersion{},
+		liveFileCount:  len(liveVersions),
+		plan: RestorePlan{
+			Unchanged: []string{},
+			Copied:    []string{},
+			Undeleted: []string{},
+			Deleted:   []string{},
+		},
+	}
+	missingVersions := []error{}
+	backedUpFiles := map[string]bool{}
+	for _, version := range bucketBackup.Versions {
+		if !strings.HasPrefix(version.BlobKey, prefix) {
+			continue
+		}
+
+		backedUpFiles[version.BlobKey] = true
+
+		if isSameBucket && !retainedVersions[version] {
+			missingVersions = append(missingVersions, fmt.Errorf("%s no longer exists", describeVersion(version)))
+			continue
+		}
+
+		liveId, exists := liveVersions[version.BlobKey]
+		switch {
+		case isSameBucket && exists && liveId == version.Id:
+			restore.plan.Unchanged = append(restore.plan.Unchanged, version.BlobKey)
+		case deletedFiles[version.BlobKey]:
+			restore.plan.Undeleted = append(restore.plan.Undeleted, version.BlobKey)
+			restore.versionsToCopy = append(restore.versionsToCopy, version)
+		default:
+			restore.plan.Copied = append(restore.plan.Copied, version.BlobKey)
+			restore.versionsToCopy = append(restore.versionsToCopy, version)
+		}
+	}
+
+	if len(missingVersions) != 0 {
+		return bucketRestore{}, formatErrors(fmt.Sprintf("cannot restore bucket '%s'; some backed up versions were permanently deleted", bucket.Name()), missingVersions)
+	}
+
+	for key := range liveVersions {
+		if !backedUpFiles[key] {
+			restore.plan.Deleted = append(restore.plan.Deleted, key)
+		}
+	}
+	sort.Strings(restore.plan.Deleted)
+
+	return restore, nil
+}
+
+func describeEndpoint(endpoint string) string {
+	if endpoint == "" {
+		return "AWS"
+	}
+	return fmt.Sprintf("endpoint '%s'", endpoint)
+}
+
+func (r bucketRestore) execute() error {
+	var err error
+	if len(r.versionsToCopy) != 0 {
+		if r.bucketBackup.Prefix != "" {
+			err = r.bucket.CopyVersionsFromPrefix(r.bucketBackup.RegionName, r.bucketBackup.BucketName, r.bucketBackup.Prefix, r.versionsToCopy)
+		} else {
+			err = r.bucket.CopyVersions(r.bucketBackup.RegionName, r.bucketBackup.BucketName, r.versionsToCopy)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	for identifier, bucket := range r.buckets {
-		bucketBackup := backup[identifier]
-		err = bucket.PutVersions(bucketBackup.RegionName, bucketBackup.BucketName, bucketBackup.Versions)
+	if len(r.plan.Deleted) != 0 {
+		err = r.bucket.DeleteFiles(r.plan.Deleted)
 		if err != nil {
 			return err
 		}
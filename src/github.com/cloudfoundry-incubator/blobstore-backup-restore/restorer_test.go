@@ -17,6 +17,7 @@ var _ = Describe("Restorer", func() {
 
 	var artifact *fakes.FakeArtifact
 
+	var summaries map[string]RestoreSummary
 	var err error
 
 	var restorer Restorer
@@ -26,132 +27,927 @@ var _ = Describe("Restorer", func() {
 		buildpacksBucket = new(fakes.FakeBucket)
 		packagesBucket = new(fakes.FakeBucket)
 
+		dropletsBucket.NameReturns("my_droplets_bucket")
+		buildpacksBucket.NameReturns("my_buildpacks_bucket")
+		packagesBucket.NameReturns("my_packages_bucket")
+
 		artifact = new(fakes.FakeArtifact)
 
 		restorer = NewRestorer(map[string]Bucket{
 			"droplets":   dropletsBucket,
 			"buildpacks": buildpacksBucket,
 			"packages":   packagesBucket,
-		}, artifact)
+		}, artifact, RestoreOptions{})
 	})
 
-	JustBeforeEach(func() {
-		err = restorer.Restore()
-	})
+	Describe("Restore", func() {
+		JustBeforeEach(func() {
+			summaries, err = restorer.Restore()
+		})
 
-	Context("when the artifact is valid and copying versions to buckets works", func() {
-		BeforeEach(func() {
-			artifact.LoadReturns(map[string]BucketBackup{
-				"droplets": {
-					BucketName: "my_droplets_bucket",
-					RegionName: "my_droplets_region",
-					Versions: []LatestVersion{
-						{BlobKey: "one", Id: "13"},
-						{BlobKey: "two", Id: "22"},
+		Context("when the artifact is valid and copying versions to buckets works", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+							{BlobKey: "two", Id: "22"},
+						},
 					},
-				},
-				"buildpacks": {
-					BucketName: "my_buildpacks_bucket",
-					RegionName: "my_buildpacks_region",
-					Versions: []LatestVersion{
-						{BlobKey: "three", Id: "32"},
+					"buildpacks": {
+						BucketName: "my_buildpacks_bucket",
+						RegionName: "my_buildpacks_region",
+						Versions: []LatestVersion{
+							{BlobKey: "three", Id: "32"},
+						},
 					},
-				},
-				"packages": {
-					BucketName: "my_packages_bucket",
-					RegionName: "my_packages_region",
-					Versions: []LatestVersion{
-						{BlobKey: "four", Id: "43"},
+					"packages": {
+						BucketName: "my_packages_bucket",
+						RegionName: "my_packages_region",
+						Versions: []LatestVersion{
+							{BlobKey: "four", Id: "43"},
+						},
 					},
-				},
-			}, nil)
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: false},
+					{Key: "one", Id: "14", IsLatest: true},
+					{Key: "two", Id: "22", IsLatest: false},
+					{Key: "two", Id: "23", IsLatest: true},
+				}, nil)
+				buildpacksBucket.VersionsReturns([]Version{
+					{Key: "three", Id: "32", IsLatest: false},
+					{Key: "three", Id: "33", IsLatest: true},
+				}, nil)
+				packagesBucket.VersionsReturns([]Version{
+					{Key: "four", Id: "43", IsLatest: false},
+					{Key: "four", Id: "44", IsLatest: true},
+				}, nil)
+
+				dropletsBucket.CopyVersionsReturns(nil)
+				buildpacksBucket.CopyVersionsReturns(nil)
+				packagesBucket.CopyVersionsReturns(nil)
+			})
+
+			It("restores a backup to the corresponding buckets", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				expectedSourceRegionName, expectedSourceBucketName, expectedVersions := dropletsBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedSourceBucketName).To(Equal("my_droplets_bucket"))
+				Expect(expectedSourceRegionName).To(Equal("my_droplets_region"))
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "one", Id: "13"},
+					{BlobKey: "two", Id: "22"},
+				}))
+
+				expectedSourceRegionName, expectedSourceBucketName, expectedVersions = buildpacksBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedSourceBucketName).To(Equal("my_buildpacks_bucket"))
+				Expect(expectedSourceRegionName).To(Equal("my_buildpacks_region"))
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "three", Id: "32"},
+				}))
 
-			dropletsBucket.PutVersionsReturns(nil)
-			buildpacksBucket.PutVersionsReturns(nil)
-			packagesBucket.PutVersionsReturns(nil)
+				expectedSourceRegionName, expectedSourceBucketName, expectedVersions = packagesBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedSourceBucketName).To(Equal("my_packages_bucket"))
+				Expect(expectedSourceRegionName).To(Equal("my_packages_region"))
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "four", Id: "43"},
+				}))
+			})
+
+			It("reports how many files were copied", func() {
+				Expect(summaries).To(Equal(map[string]RestoreSummary{
+					"droplets":   {Copied: 2},
+					"buildpacks": {Copied: 1},
+					"packages":   {Copied: 1},
+				}))
+			})
 		})
 
-		It("restores a backup to the corresponding buckets", func() {
-			Expect(err).NotTo(HaveOccurred())
+		Context("when some of the backed up versions are already current", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+							{BlobKey: "two", Id: "22"},
+							{BlobKey: "three", Id: "31"},
+						},
+					},
+				}, nil)
 
-			expectedSourceRegionName, expectedSourceBucketName, expectedVersions := dropletsBucket.PutVersionsArgsForCall(0)
-			Expect(expectedSourceBucketName).To(Equal("my_droplets_bucket"))
-			Expect(expectedSourceRegionName).To(Equal("my_droplets_region"))
-			Expect(expectedVersions).To(Equal([]LatestVersion{
-				{BlobKey: "one", Id: "13"},
-				{BlobKey: "two", Id: "22"},
-			}))
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: true},
+					{Key: "two", Id: "22", IsLatest: false},
+					{Key: "two", Id: "23", IsLatest: true},
+					{Key: "three", Id: "31", IsLatest: false},
+					{Key: "three", Id: "32", IsLatest: true},
+					{Key: "four", Id: "41", IsLatest: true},
+					{Key: "five", Id: "51", IsLatest: true},
+				}, nil)
 
-			expectedSourceRegionName, expectedSourceBucketName, expectedVersions = buildpacksBucket.PutVersionsArgsForCall(0)
-			Expect(expectedSourceBucketName).To(Equal("my_buildpacks_bucket"))
-			Expect(expectedSourceRegionName).To(Equal("my_buildpacks_region"))
-			Expect(expectedVersions).To(Equal([]LatestVersion{
-				{BlobKey: "three", Id: "32"},
-			}))
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
 
-			expectedSourceRegionName, expectedSourceBucketName, expectedVersions = packagesBucket.PutVersionsArgsForCall(0)
-			Expect(expectedSourceBucketName).To(Equal("my_packages_bucket"))
-			Expect(expectedSourceRegionName).To(Equal("my_packages_region"))
-			Expect(expectedVersions).To(Equal([]LatestVersion{
-				{BlobKey: "four", Id: "43"},
-			}))
+			It("only copies the files whose latest version differs", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(1))
+				_, _, expectedVersions := dropletsBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "two", Id: "22"},
+					{BlobKey: "three", Id: "31"},
+				}))
+			})
+
+			It("deletes the files that are not in the backup", func() {
+				Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(1))
+				Expect(dropletsBucket.DeleteFilesArgsForCall(0)).To(Equal([]string{"five", "four"}))
+			})
+
+			It("reports how many files were unchanged, copied and deleted", func() {
+				Expect(summaries).To(Equal(map[string]RestoreSummary{
+					"droplets": {Unchanged: 1, Copied: 2, Deleted: 2},
+				}))
+			})
 		})
-	})
 
-	Context("when the artifact fails to load", func() {
-		BeforeEach(func() {
-			artifact.LoadReturns(nil, errors.New("artifact failed to load"))
+		Context("when a backed up version is a `null` version", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "null"},
+							{BlobKey: "two", Id: "null"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "null", IsLatest: true},
+					{Key: "two", Id: "21", IsLatest: true},
+					{Key: "two", Id: "null", IsLatest: false},
+				}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("copies it back only if it is no longer current", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, expectedVersions := dropletsBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "two", Id: "null"},
+				}))
+				Expect(summaries).To(Equal(map[string]RestoreSummary{
+					"droplets": {Unchanged: 1, Copied: 1},
+				}))
+			})
 		})
 
-		It("stops and returns an error", func() {
-			Expect(err).To(MatchError("artifact failed to load"))
-			Expect(dropletsBucket.PutVersionsCallCount()).To(Equal(0))
-			Expect(buildpacksBucket.PutVersionsCallCount()).To(Equal(0))
-			Expect(packagesBucket.PutVersionsCallCount()).To(Equal(0))
+		Context("when every backed up version is already current", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: true},
+				}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("does not copy or delete anything", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+				Expect(summaries).To(Equal(map[string]RestoreSummary{
+					"droplets": {Unchanged: 1},
+				}))
+			})
+		})
+
+		Context("when a backed up file was deleted since the backup", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+							{BlobKey: "two", Id: "22"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: false},
+					{Key: "one", Id: "14", IsLatest: true, IsDeleteMarker: true},
+					{Key: "two", Id: "22", IsLatest: true},
+				}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("copies the backed up version back", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, expectedVersions := dropletsBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "one", Id: "13"},
+				}))
+			})
+
+			It("does not try to delete it again", func() {
+				Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+			})
+
+			It("reports it as undeleted", func() {
+				Expect(summaries).To(Equal(map[string]RestoreSummary{
+					"droplets": {Unchanged: 1, Undeleted: 1},
+				}))
+			})
+		})
+
+		Context("when a backed up version was permanently deleted", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+							{BlobKey: "two", Id: "22"},
+							{BlobKey: "three", Id: "31"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "14", IsLatest: true, IsDeleteMarker: true},
+					{Key: "two", Id: "22", IsLatest: true},
+					{Key: "four", Id: "41", IsLatest: true},
+				}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("fails without changing the bucket", func() {
+				Expect(err).To(MatchError("cannot restore bucket 'my_droplets_bucket'; some backed up versions were permanently deleted (2 error(s)):\n" +
+					"version '13' of 'one' no longer exists\n" +
+					"version '31' of 'three' no longer exists"))
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the backup was taken from a different bucket", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_old_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: true},
+				}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("copies every version, regardless of the live version ids", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				_, expectedSourceBucketName, expectedVersions := dropletsBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedSourceBucketName).To(Equal("my_old_droplets_bucket"))
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "one", Id: "13"},
+				}))
+			})
+		})
+
+		Context("when the backup was taken from a bucket with the same name on another endpoint", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Endpoint:   "https://old-blobstore.example.com",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.EndpointReturns("https://blobstore.example.com")
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "14", IsLatest: true},
+				}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("fails without changing the bucket", func() {
+				Expect(err).To(MatchError("cannot restore bucket 'my_droplets_bucket' from bucket 'my_droplets_bucket' " +
+					"on endpoint 'https://old-blobstore.example.com'"))
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the backup was taken on AWS and the bucket is on another endpoint", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.EndpointReturns("https://blobstore.example.com")
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("fails", func() {
+				Expect(err).To(MatchError("cannot restore bucket 'my_droplets_bucket' from bucket 'my_droplets_bucket' on AWS"))
+			})
+		})
+
+		Context("when the backup was copied into a backup bucket", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_backup_bucket",
+						RegionName: "my_backup_region",
+						Prefix:     "2017-11-20T10-00-00Z/droplets/",
+						Versions: []LatestVersion{
+							{BlobKey: "one"},
+							{BlobKey: "two"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: true},
+					{Key: "three", Id: "31", IsLatest: true},
+				}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("copies every file back from under the prefix", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(dropletsBucket.CopyVersionsFromPrefixCallCount()).To(Equal(1))
+				regionName, bucketName, prefix, versions := dropletsBucket.CopyVersionsFromPrefixArgsForCall(0)
+				Expect(regionName).To(Equal("my_backup_region"))
+				Expect(bucketName).To(Equal("my_backup_bucket"))
+				Expect(prefix).To(Equal("2017-11-20T10-00-00Z/droplets/"))
+				Expect(versions).To(Equal([]LatestVersion{
+					{BlobKey: "one"},
+					{BlobKey: "two"},
+				}))
+			})
+
+			It("deletes the files that are not in the backup", func() {
+				Expect(dropletsBucket.DeleteFilesArgsForCall(0)).To(Equal([]string{"three"}))
+				Expect(summaries).To(Equal(map[string]RestoreSummary{
+					"droplets": {Copied: 2, Deleted: 1},
+				}))
+			})
+		})
+
+		Context("when the artifact fails to load", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(nil, errors.New("artifact failed to load"))
+			})
+
+			It("stops and returns an error", func() {
+				Expect(err).To(MatchError("artifact failed to load"))
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(buildpacksBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(packagesBucket.CopyVersionsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when retrieving the live versions of a bucket fails", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns(nil, errors.New("failed to list versions of bucket 'my_droplets_bucket'"))
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("stops and returns an error", func() {
+				Expect(err).To(MatchError("failed to list versions of bucket 'my_droplets_bucket'"))
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when copying versions on a bucket fails", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+							{BlobKey: "two", Id: "22"},
+						},
+					},
+					"buildpacks": {
+						BucketName: "my_buildpacks_bucket",
+						RegionName: "my_buildpacks_region",
+						Versions: []LatestVersion{
+							{BlobKey: "three", Id: "32"},
+						},
+					},
+					"packages": {
+						BucketName: "my_packages_bucket",
+						RegionName: "my_packages_region",
+						Versions: []LatestVersion{
+							{BlobKey: "four", Id: "43"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: true},
+					{Key: "two", Id: "22", IsLatest: true},
+				}, nil)
+				buildpacksBucket.VersionsReturns([]Version{
+					{Key: "three", Id: "32", IsLatest: false},
+					{Key: "three", Id: "33", IsLatest: true},
+					{Key: "five", Id: "51", IsLatest: true},
+				}, nil)
+				packagesBucket.VersionsReturns([]Version{
+					{Key: "four", Id: "43", IsLatest: true},
+				}, nil)
+
+				dropletsBucket.CopyVersionsReturns(nil)
+				buildpacksBucket.CopyVersionsReturns(errors.New("failed to copy versions to bucket 'buildpacks'"))
+				packagesBucket.CopyVersionsReturns(nil)
+			})
+
+			It("stops and returns an error without deleting anything", func() {
+				Expect(err).To(MatchError("failed to copy versions to bucket 'buildpacks'"))
+
+				expectedSourceRegionName, expectedSourceBucketName, expectedVersions := buildpacksBucket.CopyVersionsArgsForCall(0)
+				Expect(expectedSourceBucketName).To(Equal("my_buildpacks_bucket"))
+				Expect(expectedSourceRegionName).To(Equal("my_buildpacks_region"))
+				Expect(expectedVersions).To(Equal([]LatestVersion{
+					{BlobKey: "three", Id: "32"},
+				}))
+				Expect(buildpacksBucket.DeleteFilesCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a bucket cannot be restored", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+						},
+					},
+					"buildpacks": {
+						BucketName: "my_buildpacks_bucket",
+						RegionName: "my_buildpacks_region",
+						Versions: []LatestVersion{
+							{BlobKey: "three", Id: "31"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: false},
+					{Key: "one", Id: "14", IsLatest: true},
+				}, nil)
+				buildpacksBucket.VersionsReturns([]Version{}, nil)
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets":   dropletsBucket,
+					"buildpacks": buildpacksBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("does not change any of the buckets", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the configured buckets do not match the buckets in the artifact", func() {
+			var options RestoreOptions
+
+			BeforeEach(func() {
+				options = RestoreOptions{}
+
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "11"},
+						},
+					},
+					"buildpacks": {
+						BucketName: "my_buildpacks_bucket",
+						RegionName: "my_buildpacks_region",
+						Versions: []LatestVersion{
+							{BlobKey: "three", Id: "31"},
+						},
+					},
+					"resources": {
+						BucketName: "my_resources_bucket",
+						RegionName: "my_resources_region",
+						Versions:   []LatestVersion{},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "11", IsLatest: false},
+					{Key: "one", Id: "12", IsLatest: true},
+				}, nil)
+				buildpacksBucket.VersionsReturns([]Version{
+					{Key: "three", Id: "31", IsLatest: true},
+				}, nil)
+				packagesBucket.VersionsReturns([]Version{
+					{Key: "four", Id: "41", IsLatest: true},
+				}, nil)
+			})
+
+			JustBeforeEach(func() {
+				summaries, err = NewRestorer(map[string]Bucket{
+					"droplets":   dropletsBucket,
+					"buildpacks": buildpacksBucket,
+					"packages":   packagesBucket,
+				}, artifact, options).Restore()
+			})
+
+			It("fails without changing any of the buckets", func() {
+				Expect(summaries).To(BeNil())
+				Expect(err).To(MatchError("the configured buckets do not match the buckets in the artifact (2 error(s)):\n" +
+					"bucket 'packages' is configured but is not in the artifact\n" +
+					"bucket 'resources' is in the artifact but is not configured"))
+				Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+				Expect(packagesBucket.DeleteFilesCallCount()).To(Equal(0))
+			})
+
+			Context("and unmatched buckets are skipped", func() {
+				BeforeEach(func() {
+					options = RestoreOptions{SkipUnmatchedBuckets: true}
+				})
+
+				It("restores the buckets that are both configured and in the artifact", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(summaries).To(Equal(map[string]RestoreSummary{
+						"droplets":   {Copied: 1},
+						"buildpacks": {Unchanged: 1},
+					}))
+					Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(1))
+				})
+
+				It("does not touch the buckets missing from the artifact", func() {
+					Expect(packagesBucket.VersionsCallCount()).To(Equal(0))
+					Expect(packagesBucket.DeleteFilesCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when a delete threshold is set", func() {
+			var newRestorer = func(deleteThreshold DeleteThreshold) Restorer {
+				return NewRestorer(map[string]Bucket{
+					"droplets":   dropletsBucket,
+					"buildpacks": buildpacksBucket,
+				}, artifact, RestoreOptions{DeleteThreshold: deleteThreshold})
+			}
+
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "11"},
+						},
+					},
+					"buildpacks": {
+						BucketName: "my_buildpacks_bucket",
+						RegionName: "my_buildpacks_region",
+						Versions: []LatestVersion{
+							{BlobKey: "five", Id: "51"},
+							{BlobKey: "six", Id: "61"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "11", IsLatest: true},
+					{Key: "two", Id: "21", IsLatest: true},
+					{Key: "three", Id: "31", IsLatest: true},
+					{Key: "four", Id: "41", IsLatest: true},
+				}, nil)
+				buildpacksBucket.VersionsReturns([]Version{
+					{Key: "five", Id: "51", IsLatest: true},
+					{Key: "six", Id: "61", IsLatest: true},
+					{Key: "seven", Id: "71", IsLatest: true},
+				}, nil)
+			})
+
+			Context("and the restore would delete more files than the maximum count", func() {
+				BeforeEach(func() {
+					restorer = newRestorer(DeleteThreshold{MaxCount: 2})
+				})
+
+				It("refuses to change any of the buckets", func() {
+					Expect(summaries).To(BeNil())
+					Expect(err).To(MatchError("restore would delete more than 2 files of a bucket (1 error(s)):\n" +
+						"bucket 'droplets' would have 3 of its 4 files (75.0%) deleted"))
+					Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+					Expect(buildpacksBucket.DeleteFilesCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("and the restore would delete more files than the maximum percentage", func() {
+				BeforeEach(func() {
+					restorer = newRestorer(DeleteThreshold{MaxPercentage: 30})
+				})
+
+				It("refuses to change any of the buckets", func() {
+					Expect(summaries).To(BeNil())
+					Expect(err).To(MatchError("restore would delete more than 30% of the files of a bucket (2 error(s)):\n" +
+						"bucket 'buildpacks' would have 1 of its 3 files (33.3%) deleted\n" +
+						"bucket 'droplets' would have 3 of its 4 files (75.0%) deleted"))
+					Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+					Expect(buildpacksBucket.DeleteFilesCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("and the restore would delete a fraction of a percent more than the maximum percentage", func() {
+				BeforeEach(func() {
+					restorer = newRestorer(DeleteThreshold{MaxPercentage: 33})
+				})
+
+				It("refuses to change any of the buckets", func() {
+					Expect(summaries).To(BeNil())
+					Expect(err).To(MatchError(ContainSubstring(
+						"bucket 'buildpacks' would have 1 of its 3 files (33.3%) deleted")))
+					Expect(buildpacksBucket.DeleteFilesCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("and the restore stays within the threshold", func() {
+				BeforeEach(func() {
+					restorer = newRestorer(DeleteThreshold{MaxCount: 3, MaxPercentage: 75})
+				})
+
+				It("deletes the files", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(dropletsBucket.DeleteFilesArgsForCall(0)).To(ConsistOf("two", "three", "four"))
+					Expect(buildpacksBucket.DeleteFilesArgsForCall(0)).To(ConsistOf("seven"))
+				})
+			})
+		})
+
+		Context("when deleting files from a bucket fails", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"droplets": {
+						BucketName: "my_droplets_bucket",
+						RegionName: "my_droplets_region",
+						Versions: []LatestVersion{
+							{BlobKey: "one", Id: "13"},
+						},
+					},
+				}, nil)
+
+				dropletsBucket.VersionsReturns([]Version{
+					{Key: "one", Id: "13", IsLatest: true},
+					{Key: "two", Id: "21", IsLatest: true},
+				}, nil)
+				dropletsBucket.DeleteFilesReturns(errors.New("failed to delete files from bucket 'my_droplets_bucket'"))
+
+				restorer = NewRestorer(map[string]Bucket{
+					"droplets": dropletsBucket,
+				}, artifact, RestoreOptions{})
+			})
+
+			It("returns the error", func() {
+				Expect(err).To(MatchError("failed to delete files from bucket 'my_droplets_bucket'"))
+				Expect(summaries).To(BeNil())
+			})
 		})
 	})
 
-	Context("when copying versions on a bucket fails", func() {
+	Describe("Plan", func() {
+		var options RestoreOptions
+		var plans map[string]RestorePlan
+
 		BeforeEach(func() {
+			options = RestoreOptions{}
+
 			artifact.LoadReturns(map[string]BucketBackup{
 				"droplets": {
 					BucketName: "my_droplets_bucket",
 					RegionName: "my_droplets_region",
 					Versions: []LatestVersion{
-						{BlobKey: "one", Id: "13"},
-						{BlobKey: "two", Id: "22"},
+						{BlobKey: "one", Id: "11"},
+						{BlobKey: "two", Id: "21"},
+						{BlobKey: "three", Id: "31"},
 					},
 				},
 				"buildpacks": {
 					BucketName: "my_buildpacks_bucket",
 					RegionName: "my_buildpacks_region",
 					Versions: []LatestVersion{
-						{BlobKey: "three", Id: "32"},
-					},
-				},
-				"packages": {
-					BucketName: "my_packages_bucket",
-					RegionName: "my_packages_region",
-					Versions: []LatestVersion{
-						{BlobKey: "four", Id: "43"},
+						{BlobKey: "five", Id: "51"},
 					},
 				},
 			}, nil)
 
-			dropletsBucket.PutVersionsReturns(nil)
-			buildpacksBucket.PutVersionsReturns(errors.New("failed to put versions to bucket 'buildpacks'"))
-			packagesBucket.PutVersionsReturns(nil)
+			buildpacksBucket.VersionsReturns([]Version{
+				{Key: "five", Id: "51", IsLatest: true},
+			}, nil)
+			dropletsBucket.VersionsReturns([]Version{
+				{Key: "one", Id: "11", IsLatest: true},
+				{Key: "two", Id: "21", IsLatest: false},
+				{Key: "two", Id: "22", IsLatest: true},
+				{Key: "three", Id: "31", IsLatest: false},
+				{Key: "three", Id: "32", IsLatest: true, IsDeleteMarker: true},
+				{Key: "four", Id: "41", IsLatest: true},
+			}, nil)
 		})
 
-		It("stops and returns an error", func() {
-			Expect(err).To(MatchError("failed to put versions to bucket 'buildpacks'"))
+		JustBeforeEach(func() {
+			plans, err = NewRestorer(map[string]Bucket{
+				"droplets":   dropletsBucket,
+				"buildpacks": buildpacksBucket,
+			}, artifact, options).Plan()
+		})
 
-			expectedSourceRegionName, expectedSourceBucketName, expectedVersions := buildpacksBucket.PutVersionsArgsForCall(0)
-			Expect(expectedSourceBucketName).To(Equal("my_buildpacks_bucket"))
-			Expect(expectedSourceRegionName).To(Equal("my_buildpacks_region"))
-			Expect(expectedVersions).To(Equal([]LatestVersion{
-				{BlobKey: "three", Id: "32"},
+		It("lists what would happen to every file", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plans).To(Equal(map[string]RestorePlan{
+				"droplets": {
+					Unchanged: []string{"one"},
+					Copied:    []string{"two"},
+					Undeleted: []string{"three"},
+					Deleted:   []string{"four"},
+				},
+				"buildpacks": {
+					Unchanged: []string{"five"},
+					Copied:    []string{},
+					Undeleted: []string{},
+					Deleted:   []string{},
+				},
 			}))
+			Expect(plans["droplets"].Summary()).To(Equal(RestoreSummary{Unchanged: 1, Copied: 1, Undeleted: 1, Deleted: 1}))
+		})
+
+		It("does not change the bucket", func() {
+			Expect(dropletsBucket.CopyVersionsCallCount()).To(Equal(0))
+			Expect(dropletsBucket.CopyVersionsFromPrefixCallCount()).To(Equal(0))
+			Expect(dropletsBucket.DeleteFilesCallCount()).To(Equal(0))
+		})
+
+		Context("when the restore would delete more files than the delete threshold", func() {
+			BeforeEach(func() {
+				options = RestoreOptions{DeleteThreshold: DeleteThreshold{MaxPercentage: 33}}
+			})
+
+			It("reports which buckets the restore would be refused for", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plans["droplets"].ExceedsDeleteThreshold).To(BeTrue())
+				Expect(plans["buildpacks"].ExceedsDeleteThreshold).To(BeFalse())
+			})
+		})
+
+		Context("when restricted to some of the buckets", func() {
+			BeforeEach(func() {
+				options = RestoreOptions{Buckets: []string{"buildpacks"}}
+			})
+
+			It("only plans the restore of those buckets", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plans).To(HaveLen(1))
+				Expect(plans).To(HaveKey("buildpacks"))
+				Expect(dropletsBucket.VersionsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when restricted to a bucket that is not configured", func() {
+			BeforeEach(func() {
+				options = RestoreOptions{Buckets: []string{"packages"}}
+			})
+
+			It("returns an error", func() {
+				Expect(plans).To(BeNil())
+				Expect(err).To(MatchError("cannot restore bucket 'packages' as it is not configured"))
+			})
+		})
+
+		Context("when restricted to a bucket that is not in the artifact", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(map[string]BucketBackup{
+					"buildpacks": {
+						BucketName: "my_buildpacks_bucket",
+						RegionName: "my_buildpacks_region",
+						Versions:   []LatestVersion{},
+					},
+				}, nil)
+				options = RestoreOptions{Buckets: []string{"droplets"}}
+			})
+
+			It("returns an error", func() {
+				Expect(plans).To(BeNil())
+				Expect(err).To(MatchError(ContainSubstring("bucket 'droplets' is configured but is not in the artifact")))
+			})
+		})
+
+		Context("when restricted to a key prefix", func() {
+			BeforeEach(func() {
+				options = RestoreOptions{Prefix: "t"}
+			})
+
+			It("only copies and deletes the files with that prefix", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plans["droplets"]).To(Equal(RestorePlan{
+					Unchanged: []string{},
+					Copied:    []string{"two"},
+					Undeleted: []string{"three"},
+					Deleted:   []string{},
+				}))
+				Expect(plans["buildpacks"].Summary()).To(Equal(RestoreSummary{}))
+			})
+		})
+
+		Context("when the artifact cannot be loaded", func() {
+			BeforeEach(func() {
+				artifact.LoadReturns(nil, errors.New("could not read backup file"))
+			})
+
+			It("returns the error", func() {
+				Expect(plans).To(BeNil())
+				Expect(err).To(MatchError("could not read backup file"))
+			})
 		})
 	})
 })
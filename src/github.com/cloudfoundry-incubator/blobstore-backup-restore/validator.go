@@ -0,0 +1,36 @@
+package blobstore
+
+import "sort"
+
+type VersioningValidator struct {
+	buckets map[string]Bucket
+}
+
+func NewVersioningValidator(buckets map[string]Bucket) VersioningValidator {
+	return VersioningValidator{buckets: buckets}
+}
+
+func (v VersioningValidator) Validate() error {
+	errs := []error{}
+	for _, identifier := range sortedIdentifiers(v.buckets) {
+		err := v.buckets[identifier].CheckVersioning()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) != 0 {
+		return formatErrors("buckets must have versioning enabled", errs)
+	}
+
+	return nil
+}
+
+func sortedIdentifiers(buckets map[string]Bucket) []string {
+	identifiers := []string{}
+	for identifier := range buckets {
+		identifiers = append(identifiers, identifier)
+	}
+	sort.Strings(identifiers)
+	return identifiers
+}
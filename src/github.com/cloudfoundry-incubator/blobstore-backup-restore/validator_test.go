@@ -0,0 +1,55 @@
+package blobstore_test
+
+import (
+	. "github.com/cloudfoundry-incubator/blobstore-backup-restore"
+
+	"errors"
+
+	"github.com/cloudfoundry-incubator/blobstore-backup-restore/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("VersioningValidator", func() {
+	var dropletsBucket *fakes.FakeBucket
+	var buildpacksBucket *fakes.FakeBucket
+	var packagesBucket *fakes.FakeBucket
+
+	var err error
+
+	BeforeEach(func() {
+		dropletsBucket = new(fakes.FakeBucket)
+		buildpacksBucket = new(fakes.FakeBucket)
+		packagesBucket = new(fakes.FakeBucket)
+	})
+
+	JustBeforeEach(func() {
+		err = NewVersioningValidator(map[string]Bucket{
+			"droplets":   dropletsBucket,
+			"buildpacks": buildpacksBucket,
+			"packages":   packagesBucket,
+		}).Validate()
+	})
+
+	Context("when every bucket has versioning enabled", func() {
+		It("succeeds", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dropletsBucket.CheckVersioningCallCount()).To(Equal(1))
+			Expect(buildpacksBucket.CheckVersioningCallCount()).To(Equal(1))
+			Expect(packagesBucket.CheckVersioningCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when some buckets do not have versioning enabled", func() {
+		BeforeEach(func() {
+			dropletsBucket.CheckVersioningReturns(errors.New("versioning is suspended on bucket 'my_droplets_bucket'"))
+			packagesBucket.CheckVersioningReturns(errors.New("versioning has never been enabled on bucket 'my_packages_bucket'"))
+		})
+
+		It("reports every one of them", func() {
+			Expect(err).To(MatchError("buckets must have versioning enabled (2 error(s)):\n" +
+				"versioning is suspended on bucket 'my_droplets_bucket'\n" +
+				"versioning has never been enabled on bucket 'my_packages_bucket'"))
+		})
+	})
+})
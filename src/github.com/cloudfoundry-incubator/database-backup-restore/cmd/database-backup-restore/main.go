@@ -28,7 +28,7 @@ func main() {
 	flags, err := config.ParseFlags()
 	if err != nil {
 		log.Fatalf("%s\nUsage: database-backup-restorer [--backup|--restore] --config <config-file> "+
-			"--artifact-file <artifact-file>\n", err)
+			"[--artifact-file <artifact-file>|--artifact-directory <artifact-directory>]\n", err)
 	}
 
 	connectionConfig, err := config.ParseAndValidateConnectionConfig(flags.ConfigPath)
@@ -36,21 +36,150 @@ func main() {
 		log.Fatalf("%v", err)
 	}
 
+	hasSeveralDatabases := connectionConfig.Databases != nil || connectionConfig.AllDatabases
+	if hasSeveralDatabases && flags.ArtifactDirectoryPath == "" {
+		log.Fatalf("--artifact-directory must be provided when the config has several databases\n")
+	}
+	if !hasSeveralDatabases && flags.ArtifactFilePath == "" {
+		log.Fatalf("--artifact-file must be provided when the config has a single database\n")
+	}
+
 	utilitiesConfig := config.GetUtilitiesConfigFromEnv()
 
-	interactor, err := makeInteractor(flags.IsRestore, utilitiesConfig, connectionConfig)
+	// log.Fatalf doesn't run deferred functions, so fatalf removes the TLS
+	// certificates before exiting
+	fatalf := log.Fatalf
+	if connectionConfig.TLS != nil {
+		removeCertFiles, err := connectionConfig.TLS.WriteCertFiles()
+		if err != nil {
+			log.Fatalf("Could not write TLS certificates: %v\n", err)
+		}
+		defer removeCertFiles()
+		fatalf = func(format string, v ...interface{}) {
+			removeCertFiles()
+			log.Fatalf(format, v...)
+		}
+	}
+
+	var interactor database.Interactor
+	var artifactPath string
+	var createdArtifact string
+	if connectionConfig.AllDatabases {
+		interactor, err = makeAllDatabasesInteractor(flags, utilitiesConfig, connectionConfig)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		artifactPath = flags.ArtifactDirectoryPath
+		createdArtifact = "files that were created in the artifact-directory"
+	} else if connectionConfig.Databases != nil {
+		var interactors []database.DatabaseInteractor
+		for _, databaseConfig := range connectionConfig.DatabaseConnectionConfigs() {
+			databaseInteractor, err := makeInteractor(flags, utilitiesConfig, databaseConfig)
+			if err != nil {
+				fatalf("%s: %v", databaseConfig.Database, err)
+			}
+			interactors = append(interactors, database.DatabaseInteractor{
+				Name:       databaseConfig.Database,
+				Interactor: databaseInteractor,
+			})
+		}
+		interactor = database.NewMultiDatabaseInteractor(interactors)
+		artifactPath = flags.ArtifactDirectoryPath
+		createdArtifact = "files that were created in the artifact-directory"
+	} else {
+		interactor, err = makeInteractor(flags, utilitiesConfig, connectionConfig)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		artifactPath = flags.ArtifactFilePath
+		createdArtifact = "artifact-file that was created"
+	}
+
+	err = interactor.Action(artifactPath)
 	if err != nil {
-		log.Fatalf("%v", err)
+		fatalf(
+			"You may need to delete the %s before re-running.\n%s\n", createdArtifact, err)
+	}
+}
+
+func makeAllDatabasesInteractor(flags config.CommandFlags, utilitiesConfig config.UtilitiesConfig,
+	connectionConfig config.ConnectionConfig) (database.Interactor, error) {
+
+	factory := database.NewInteractorFactory(
+		utilitiesConfig,
+		postgres.NewServerVersionDetector(utilitiesConfig.Postgres96.Client))
+
+	databaseManager, err := factory.MakeDatabaseManager(connectionConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	globalsInteractor, err := factory.MakeGlobalsInteractor(actionLabel(flags.IsRestore), connectionConfig)
+	if err != nil {
+		return nil, err
 	}
+	if globalsInteractor != nil {
+		globalsInteractor, err = wrapInteractor(flags, utilitiesConfig, connectionConfig, globalsInteractor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	makeDatabaseInteractor := func(databaseName string) (database.Interactor, error) {
+		databaseConfig := connectionConfig
+		databaseConfig.Database = databaseName
+		databaseConfig.AllDatabases = false
+		return makeInteractor(flags, utilitiesConfig, databaseConfig)
+	}
+
+	if flags.IsRestore {
+		return database.NewAllDatabasesRestorer(databaseManager, globalsInteractor, makeDatabaseInteractor, flags.RequireChecksum), nil
+	}
+	return database.NewAllDatabasesBackuper(databaseManager, globalsInteractor, makeDatabaseInteractor), nil
+}
+
+func makeInteractor(flags config.CommandFlags, utilitiesConfig config.UtilitiesConfig,
+	connectionConfig config.ConnectionConfig) (database.Interactor, error) {
 
-	err = interactor.Action(flags.ArtifactFilePath)
+	interactor, err := makeDatabaseInteractor(flags.IsRestore, utilitiesConfig, connectionConfig)
 	if err != nil {
-		log.Fatalf(
-			"You may need to delete the artifact-file that was created before re-running.\n%s\n", err)
+		return nil, err
+	}
+
+	return wrapInteractor(flags, utilitiesConfig, connectionConfig, interactor)
+}
+
+// wrapInteractor adds the checksum, encryption and compression of the
+// artifact to the interactor.
+func wrapInteractor(flags config.CommandFlags, utilitiesConfig config.UtilitiesConfig,
+	connectionConfig config.ConnectionConfig, interactor database.Interactor) (database.Interactor, error) {
+
+	var encryptor *database.Encryptor
+	if connectionConfig.EncryptionKey != "" {
+		e := database.NewEncryptor(connectionConfig.EncryptionKey)
+		encryptor = &e
+	}
+
+	if flags.IsRestore {
+		interactor = database.NewDecompressingInteractor(interactor)
+		interactor = database.NewDecryptingInteractor(interactor, encryptor)
+		return database.NewChecksumVerifyingInteractor(interactor, flags.RequireChecksum), nil
+	}
+
+	compressor, err := database.NewCompressor(connectionConfig.Compression)
+	if err != nil {
+		return nil, err
+	}
+	if compressor != nil {
+		interactor = database.NewCompressingInteractor(interactor, compressor)
+	}
+	if encryptor != nil {
+		interactor = database.NewEncryptingInteractor(interactor, *encryptor)
 	}
+	return database.NewChecksumWritingInteractor(interactor), nil
 }
 
-func makeInteractor(isRestoreAction bool, utilitiesConfig config.UtilitiesConfig,
+func makeDatabaseInteractor(isRestoreAction bool, utilitiesConfig config.UtilitiesConfig,
 	config config.ConnectionConfig) (database.Interactor, error) {
 
 	postgresServerVersionDetector := postgres.NewServerVersionDetector(utilitiesConfig.Postgres96.Client)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"strings"
 )
 
 type ConnectionConfig struct {
@@ -14,6 +15,39 @@ type ConnectionConfig struct {
 	Host     string   `json:"host"`
 	Database string   `json:"database"`
 	Tables   []string `json:"tables"`
+
+	// Databases replaces Database and Tables to back up several databases
+	// on the same server, each into its own file.
+	Databases []DatabaseConfig `json:"databases"`
+
+	// AllDatabases replaces Database and Tables to back up every database
+	// on the server, apart from the system databases.
+	AllDatabases bool `json:"all_databases"`
+
+	// TLS is nil when the server is connected to without TLS
+	TLS *TLSConfig `json:"tls"`
+
+	Compression   string `json:"compression"`
+	EncryptionKey string `json:"encryption_key"`
+}
+
+type DatabaseConfig struct {
+	Name   string   `json:"name"`
+	Tables []string `json:"tables"`
+}
+
+// DatabaseConnectionConfigs returns a config for each of the databases, to
+// back them up and restore them one at a time.
+func (c ConnectionConfig) DatabaseConnectionConfigs() []ConnectionConfig {
+	var configs []ConnectionConfig
+	for _, database := range c.Databases {
+		databaseConfig := c
+		databaseConfig.Database = database.Name
+		databaseConfig.Tables = database.Tables
+		databaseConfig.Databases = nil
+		configs = append(configs, databaseConfig)
+	}
+	return configs
 }
 
 func ParseAndValidateConnectionConfig(configPath string) (ConnectionConfig, error) {
@@ -35,6 +69,18 @@ func ParseAndValidateConnectionConfig(configPath string) (ConnectionConfig, erro
 		return ConnectionConfig{}, fmt.Errorf("Tables specified but empty\n")
 	}
 
+	if err := validateTLS(connectionConfig.TLS); err != nil {
+		return ConnectionConfig{}, err
+	}
+
+	if err := validateDatabases(connectionConfig); err != nil {
+		return ConnectionConfig{}, err
+	}
+
+	if !isSupportedCompression(connectionConfig.Compression) {
+		return ConnectionConfig{}, fmt.Errorf("Unsupported compression %s\n", connectionConfig.Compression)
+	}
+
 	return connectionConfig, nil
 }
 
@@ -48,3 +94,88 @@ func isSupported(adapter string) bool {
 	}
 	return false
 }
+
+func validateTLS(tlsConfig *TLSConfig) error {
+	if tlsConfig == nil {
+		return nil
+	}
+
+	if tlsConfig.VerifyMode == "" {
+		tlsConfig.VerifyMode = TLSVerifyFull
+	}
+
+	if !isSupportedTLSVerifyMode(tlsConfig.VerifyMode) {
+		return fmt.Errorf("Unsupported TLS verify_mode %s\n", tlsConfig.VerifyMode)
+	}
+
+	if (tlsConfig.Cert.Certificate == "") != (tlsConfig.Cert.PrivateKey == "") {
+		return fmt.Errorf("TLS client certificate and private key must be provided together\n")
+	}
+
+	return nil
+}
+
+var supportedTLSVerifyModes = []string{TLSVerifyNone, TLSVerifyCA, TLSVerifyFull}
+
+func isSupportedTLSVerifyMode(verifyMode string) bool {
+	for _, el := range supportedTLSVerifyModes {
+		if el == verifyMode {
+			return true
+		}
+	}
+	return false
+}
+
+func validateDatabases(connectionConfig ConnectionConfig) error {
+	databaseFieldsCount := 0
+	if connectionConfig.Database != "" || connectionConfig.Tables != nil {
+		databaseFieldsCount++
+	}
+	if connectionConfig.Databases != nil {
+		databaseFieldsCount++
+	}
+	if connectionConfig.AllDatabases {
+		databaseFieldsCount++
+	}
+
+	if databaseFieldsCount > 1 {
+		return fmt.Errorf("Only one of: database, databases or all_databases can be specified\n")
+	}
+
+	if connectionConfig.Databases == nil {
+		return nil
+	}
+
+	if len(connectionConfig.Databases) == 0 {
+		return fmt.Errorf("Databases specified but empty\n")
+	}
+
+	names := map[string]bool{}
+	for _, database := range connectionConfig.Databases {
+		if database.Name == "" || strings.ContainsAny(database.Name, "/\\") || database.Name == "." || database.Name == ".." {
+			return fmt.Errorf("Invalid database name '%s'\n", database.Name)
+		}
+
+		if names[database.Name] {
+			return fmt.Errorf("Database %s specified more than once\n", database.Name)
+		}
+		names[database.Name] = true
+
+		if database.Tables != nil && len(database.Tables) == 0 {
+			return fmt.Errorf("Tables specified but empty for database %s\n", database.Name)
+		}
+	}
+
+	return nil
+}
+
+var supportedCompressions = []string{"", "gzip", "zstd"}
+
+func isSupportedCompression(compression string) bool {
+	for _, el := range supportedCompressions {
+		if el == compression {
+			return true
+		}
+	}
+	return false
+}
@@ -9,6 +9,10 @@ type CommandFlags struct {
 	ConfigPath       string
 	IsRestore        bool
 	ArtifactFilePath string
+	// ArtifactDirectoryPath is used instead of ArtifactFilePath when the
+	// config has several databases
+	ArtifactDirectoryPath string
+	RequireChecksum       bool
 }
 
 func ParseFlags() (CommandFlags, error) {
@@ -16,6 +20,8 @@ func ParseFlags() (CommandFlags, error) {
 	var backupAction = flag.Bool("backup", false, "Run database backup")
 	var restoreAction = flag.Bool("restore", false, "Run database restore")
 	var artifactFilePath = flag.String("artifact-file", "", "Path to output file")
+	var artifactDirectoryPath = flag.String("artifact-directory", "", "Path to output directory, when backing up several databases")
+	var requireChecksum = flag.Bool("require-checksum", false, "Fail to restore an artifact that has no checksum file, instead of restoring it without verification")
 
 	flag.Parse()
 
@@ -31,13 +37,23 @@ func ParseFlags() (CommandFlags, error) {
 		return CommandFlags{}, errors.New("Missing --config flag")
 	}
 
-	if *artifactFilePath == "" {
-		return CommandFlags{}, errors.New("Missing --artifact-file flag")
+	if *artifactFilePath != "" && *artifactDirectoryPath != "" {
+		return CommandFlags{}, errors.New("Only one of: --artifact-file or --artifact-directory can be provided")
+	}
+
+	if *artifactFilePath == "" && *artifactDirectoryPath == "" {
+		return CommandFlags{}, errors.New("Missing --artifact-file or --artifact-directory flag")
+	}
+
+	if *requireChecksum && !*restoreAction {
+		return CommandFlags{}, errors.New("--require-checksum can only be provided with --restore")
 	}
 
 	return CommandFlags{
-		ConfigPath:       *configPath,
-		IsRestore:        *restoreAction,
-		ArtifactFilePath: *artifactFilePath,
+		ConfigPath:            *configPath,
+		IsRestore:             *restoreAction,
+		ArtifactFilePath:      *artifactFilePath,
+		ArtifactDirectoryPath: *artifactDirectoryPath,
+		RequireChecksum:       *requireChecksum,
 	}, nil
 }
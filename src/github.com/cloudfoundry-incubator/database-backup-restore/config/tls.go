@@ -0,0 +1,81 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// The server's certificate is verified against the CA and for the host with
+// TLSVerifyFull, the default, only against the CA with TLSVerifyCA, and not at
+// all with TLSVerifyNone, which still encrypts the connection.
+const (
+	TLSVerifyNone = "none"
+	TLSVerifyCA   = "ca"
+	TLSVerifyFull = "full"
+)
+
+// systemCAPath is the stemcell's bundle of trusted CAs, which the server's
+// certificate is verified against when no CA is configured.
+const systemCAPath = "/etc/ssl/certs/ca-certificates.crt"
+
+type TLSConfig struct {
+	VerifyMode string        `json:"verify_mode"`
+	Cert       TLSCertConfig `json:"cert"`
+
+	// the clients read the certificates from files, which are written by
+	// WriteCertFiles. CAPath is empty when the server's certificate isn't
+	// verified.
+	CAPath          string `json:"-"`
+	CertificatePath string `json:"-"`
+	PrivateKeyPath  string `json:"-"`
+}
+
+type TLSCertConfig struct {
+	CA          string `json:"ca"`
+	Certificate string `json:"certificate"`
+	PrivateKey  string `json:"private_key"`
+}
+
+// WriteCertFiles writes the certificates to a directory that only the
+// current user can read, and returns a function that removes them.
+func (c *TLSConfig) WriteCertFiles() (func(), error) {
+	certDir, err := ioutil.TempDir("", "database-backup-restore-tls")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.RemoveAll(certDir) }
+
+	switch {
+	case c.VerifyMode == TLSVerifyNone:
+	case c.Cert.CA == "":
+		c.CAPath = systemCAPath
+	default:
+		c.CAPath, err = writeCertFile(certDir, "ca.pem", c.Cert.CA)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	if c.Cert.Certificate != "" {
+		c.CertificatePath, err = writeCertFile(certDir, "certificate.pem", c.Cert.Certificate)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		c.PrivateKeyPath, err = writeCertFile(certDir, "private_key.pem", c.Cert.PrivateKey)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	return cleanup, nil
+}
+
+func writeCertFile(certDir, name, contents string) (string, error) {
+	path := filepath.Join(certDir, name)
+	return path, ioutil.WriteFile(path, []byte(contents), 0600)
+}
@@ -15,6 +15,8 @@ type UtilitiesConfig struct {
 	Postgres96 UtilityPaths
 	Postgres94 UtilityPaths
 	Mysql      UtilityPaths
+
+	PostgresDumpAll string
 }
 
 func GetUtilitiesConfigFromEnv() UtilitiesConfig {
@@ -34,6 +36,8 @@ func GetUtilitiesConfigFromEnv() UtilitiesConfig {
 			Dump:    lookupEnv("MYSQL_DUMP_PATH"),
 			Restore: lookupEnv("MYSQL_CLIENT_PATH"),
 		},
+		// only needed to back up all the databases on a Postgres server
+		PostgresDumpAll: os.Getenv("PG_DUMPALL_PATH"),
 	}
 }
 
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/sha256sum"
+)
+
+// A backup of all the databases is a directory with a manifest of the
+// databases, a file for each of them in the databases directory and, when
+// the adapter has any, a file of the server's globals.
+const (
+	allDatabasesManifestFileName = "databases.json"
+	allDatabasesDirectoryName    = "databases"
+	globalsFileName              = "globals.sql"
+)
+
+type allDatabasesManifest struct {
+	Databases []string `json:"databases"`
+	Globals   bool     `json:"globals"`
+}
+
+type AllDatabasesBackuper struct {
+	databaseManager DatabaseManager
+	globalsBackuper Interactor
+	makeInteractor  func(databaseName string) (Interactor, error)
+}
+
+// NewAllDatabasesBackuper takes a nil globalsBackuper when the adapter has no
+// globals to back up.
+func NewAllDatabasesBackuper(databaseManager DatabaseManager, globalsBackuper Interactor,
+	makeInteractor func(databaseName string) (Interactor, error)) AllDatabasesBackuper {
+	return AllDatabasesBackuper{
+		databaseManager: databaseManager,
+		globalsBackuper: globalsBackuper,
+		makeInteractor:  makeInteractor,
+	}
+}
+
+func (b AllDatabasesBackuper) Action(artifactDirectoryPath string) error {
+	databaseNames, err := b.databaseManager.ListDatabases()
+	if err != nil {
+		return fmt.Errorf("could not list databases: %s", err.Error())
+	}
+	log.Printf("backing up databases %v\n", databaseNames)
+
+	var interactors []DatabaseInteractor
+	for _, databaseName := range databaseNames {
+		if !isValidDatabaseFileName(databaseName) {
+			return fmt.Errorf("cannot back up database '%s' as its name is not a valid file name", databaseName)
+		}
+
+		interactor, err := b.makeInteractor(databaseName)
+		if err != nil {
+			return err
+		}
+		interactors = append(interactors, DatabaseInteractor{Name: databaseName, Interactor: interactor})
+	}
+
+	databasesDirectoryPath := filepath.Join(artifactDirectoryPath, allDatabasesDirectoryName)
+	err = os.MkdirAll(databasesDirectoryPath, 0700)
+	if err != nil {
+		return fmt.Errorf("could not create databases directory: %s", err.Error())
+	}
+
+	if b.globalsBackuper != nil {
+		err = b.globalsBackuper.Action(filepath.Join(artifactDirectoryPath, globalsFileName))
+		if err != nil {
+			return fmt.Errorf("could not back up globals: %s", err.Error())
+		}
+	}
+
+	err = NewMultiDatabaseInteractor(interactors).Action(databasesDirectoryPath)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := json.Marshal(allDatabasesManifest{
+		Databases: databaseNames,
+		Globals:   b.globalsBackuper != nil,
+	})
+	if err != nil {
+		return err
+	}
+
+	manifestFilePath := filepath.Join(artifactDirectoryPath, allDatabasesManifestFileName)
+	err = sha256sum.WriteFileAtomically(manifestFilePath, manifest)
+	if err != nil {
+		return fmt.Errorf("could not write the manifest of the databases: %s", err.Error())
+	}
+
+	return sha256sum.Write(manifestFilePath)
+}
+
+type AllDatabasesRestorer struct {
+	databaseManager DatabaseManager
+	globalsRestorer Interactor
+	makeInteractor  func(databaseName string) (Interactor, error)
+	requireChecksum bool
+}
+
+// NewAllDatabasesRestorer takes a nil globalsRestorer when the adapter has no
+// globals to restore. requireChecksum applies to the manifest as it does to
+// the files of the databases.
+func NewAllDatabasesRestorer(databaseManager DatabaseManager, globalsRestorer Interactor,
+	makeInteractor func(databaseName string) (Interactor, error), requireChecksum bool) AllDatabasesRestorer {
+	return AllDatabasesRestorer{
+		databaseManager: databaseManager,
+		globalsRestorer: globalsRestorer,
+		makeInteractor:  makeInteractor,
+		requireChecksum: requireChecksum,
+	}
+}
+
+func (r AllDatabasesRestorer) Action(artifactDirectoryPath string) error {
+	manifestFilePath := filepath.Join(artifactDirectoryPath, allDatabasesManifestFileName)
+	err := sha256sum.Verify(manifestFilePath, r.requireChecksum)
+	if err != nil {
+		return err
+	}
+
+	contents, err := ioutil.ReadFile(manifestFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read the manifest of the databases: %s", err.Error())
+	}
+
+	var manifest allDatabasesManifest
+	err = json.Unmarshal(contents, &manifest)
+	if err != nil {
+		return fmt.Errorf("could not parse the manifest of the databases: %s", err.Error())
+	}
+
+	if manifest.Globals {
+		if r.globalsRestorer == nil {
+			return errors.New("the artifact has globals, which cannot be restored with this adapter")
+		}
+
+		err = r.globalsRestorer.Action(filepath.Join(artifactDirectoryPath, globalsFileName))
+		if err != nil {
+			return fmt.Errorf("could not restore globals: %s", err.Error())
+		}
+	}
+
+	var interactors []DatabaseInteractor
+	for _, databaseName := range manifest.Databases {
+		if !isValidDatabaseFileName(databaseName) {
+			return fmt.Errorf("cannot restore database '%s' as its name is not a valid file name", databaseName)
+		}
+
+		interactors = append(interactors, DatabaseInteractor{
+			Name: databaseName,
+			Interactor: databaseCreatingInteractor{
+				databaseManager: r.databaseManager,
+				databaseName:    databaseName,
+				makeInteractor:  r.makeInteractor,
+			},
+		})
+	}
+
+	return NewMultiDatabaseInteractor(interactors).Action(filepath.Join(artifactDirectoryPath, allDatabasesDirectoryName))
+}
+
+// databaseCreatingInteractor creates the database, if it doesn't exist, before
+// restoring it. The interactor is only made once the database exists, as
+// making it may connect to the database.
+type databaseCreatingInteractor struct {
+	databaseManager DatabaseManager
+	databaseName    string
+	makeInteractor  func(databaseName string) (Interactor, error)
+}
+
+func (i databaseCreatingInteractor) Action(artifactFilePath string) error {
+	err := i.databaseManager.CreateDatabase(i.databaseName)
+	if err != nil {
+		return fmt.Errorf("could not create database: %s", err.Error())
+	}
+
+	interactor, err := i.makeInteractor(i.databaseName)
+	if err != nil {
+		return err
+	}
+
+	return interactor.Action(artifactFilePath)
+}
+
+func isValidDatabaseFileName(databaseName string) bool {
+	return databaseName != "" && databaseName != "." && databaseName != ".." &&
+		filepath.Base(databaseName) == databaseName
+}
@@ -0,0 +1,227 @@
+package database_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/database"
+	"github.com/cloudfoundry-incubator/database-backup-restore/database/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("All databases interactors", func() {
+	var databaseManager *fakes.FakeDatabaseManager
+	var globalsInteractor *fakes.FakeInteractor
+	var databaseInteractors map[string]*fakes.FakeInteractor
+	var makeInteractor func(databaseName string) (database.Interactor, error)
+	var artifactDirectory string
+	var err error
+
+	BeforeEach(func() {
+		databaseManager = new(fakes.FakeDatabaseManager)
+		globalsInteractor = new(fakes.FakeInteractor)
+		databaseInteractors = map[string]*fakes.FakeInteractor{
+			"ccdb":  new(fakes.FakeInteractor),
+			"uaadb": new(fakes.FakeInteractor),
+		}
+		makeInteractor = func(databaseName string) (database.Interactor, error) {
+			return databaseInteractors[databaseName], nil
+		}
+
+		artifactDirectory, err = ioutil.TempDir("", "all_databases_test_")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(artifactDirectory)
+	})
+
+	Describe("AllDatabasesBackuper", func() {
+		BeforeEach(func() {
+			databaseManager.ListDatabasesReturns([]string{"ccdb", "uaadb"}, nil)
+		})
+
+		JustBeforeEach(func() {
+			err = database.NewAllDatabasesBackuper(databaseManager, globals(globalsInteractor), makeInteractor).
+				Action(artifactDirectory)
+		})
+
+		It("backs up the globals", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(globalsInteractor.ActionArgsForCall(0)).To(Equal(filepath.Join(artifactDirectory, "globals.sql")))
+		})
+
+		It("backs up every database into the databases directory", func() {
+			Expect(databaseInteractors["ccdb"].ActionArgsForCall(0)).To(Equal(filepath.Join(artifactDirectory, "databases", "ccdb.dump")))
+			Expect(databaseInteractors["uaadb"].ActionArgsForCall(0)).To(Equal(filepath.Join(artifactDirectory, "databases", "uaadb.dump")))
+		})
+
+		It("writes a manifest of the databases", func() {
+			manifest, err := ioutil.ReadFile(filepath.Join(artifactDirectory, "databases.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest).To(MatchJSON(`{"databases": ["ccdb", "uaadb"], "globals": true}`))
+		})
+
+		It("writes the checksum of the manifest", func() {
+			manifest, err := ioutil.ReadFile(filepath.Join(artifactDirectory, "databases.json"))
+			Expect(err).NotTo(HaveOccurred())
+			checksum, err := ioutil.ReadFile(filepath.Join(artifactDirectory, "databases.json.sha256"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(checksum)).To(Equal(fmt.Sprintf("%x  databases.json\n", sha256.Sum256(manifest))))
+		})
+
+		Context("when the adapter has no globals", func() {
+			BeforeEach(func() {
+				globalsInteractor = nil
+			})
+
+			It("records that in the manifest", func() {
+				Expect(err).NotTo(HaveOccurred())
+				manifest, err := ioutil.ReadFile(filepath.Join(artifactDirectory, "databases.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest).To(MatchJSON(`{"databases": ["ccdb", "uaadb"], "globals": false}`))
+			})
+		})
+
+		Context("when listing the databases fails", func() {
+			BeforeEach(func() {
+				databaseManager.ListDatabasesReturns(nil, fmt.Errorf("server is down"))
+			})
+
+			It("fails without backing anything up", func() {
+				Expect(err).To(MatchError("could not list databases: server is down"))
+				Expect(globalsInteractor.ActionCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when a database's name is not a valid file name", func() {
+			BeforeEach(func() {
+				databaseManager.ListDatabasesReturns([]string{"ccdb", "a/b"}, nil)
+			})
+
+			It("fails without backing anything up", func() {
+				Expect(err).To(MatchError("cannot back up database 'a/b' as its name is not a valid file name"))
+				Expect(databaseInteractors["ccdb"].ActionCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when backing up a database fails", func() {
+			BeforeEach(func() {
+				databaseInteractors["ccdb"].ActionReturns(fmt.Errorf("dump failed"))
+			})
+
+			It("still backs up the other databases, and fails", func() {
+				Expect(err).To(MatchError(ContainSubstring("ccdb: dump failed")))
+				Expect(databaseInteractors["uaadb"].ActionCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("AllDatabasesRestorer", func() {
+		var requireChecksum bool
+
+		BeforeEach(func() {
+			requireChecksum = false
+			Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, "databases.json"),
+				[]byte(`{"databases": ["ccdb", "uaadb"], "globals": true}`), 0600)).To(Succeed())
+		})
+
+		JustBeforeEach(func() {
+			err = database.NewAllDatabasesRestorer(databaseManager, globals(globalsInteractor), makeInteractor, requireChecksum).
+				Action(artifactDirectory)
+		})
+
+		It("restores the globals", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(globalsInteractor.ActionArgsForCall(0)).To(Equal(filepath.Join(artifactDirectory, "globals.sql")))
+		})
+
+		It("creates and restores every database in the manifest", func() {
+			Expect(databaseManager.CreateDatabaseCallCount()).To(Equal(2))
+			Expect(databaseManager.CreateDatabaseArgsForCall(0)).To(Equal("ccdb"))
+			Expect(databaseManager.CreateDatabaseArgsForCall(1)).To(Equal("uaadb"))
+
+			Expect(databaseInteractors["ccdb"].ActionArgsForCall(0)).To(Equal(filepath.Join(artifactDirectory, "databases", "ccdb.dump")))
+			Expect(databaseInteractors["uaadb"].ActionArgsForCall(0)).To(Equal(filepath.Join(artifactDirectory, "databases", "uaadb.dump")))
+		})
+
+		Context("when creating a database fails", func() {
+			BeforeEach(func() {
+				databaseManager.CreateDatabaseReturnsOnCall(0, fmt.Errorf("permission denied"))
+			})
+
+			It("does not restore it, but restores the other databases", func() {
+				Expect(err).To(MatchError(ContainSubstring("ccdb: could not create database: permission denied")))
+				Expect(databaseInteractors["ccdb"].ActionCallCount()).To(Equal(0))
+				Expect(databaseInteractors["uaadb"].ActionCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when restoring the globals fails", func() {
+			BeforeEach(func() {
+				globalsInteractor.ActionReturns(fmt.Errorf("psql failed"))
+			})
+
+			It("fails without restoring the databases", func() {
+				Expect(err).To(MatchError("could not restore globals: psql failed"))
+				Expect(databaseManager.CreateDatabaseCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the artifact has globals but the adapter has none", func() {
+			BeforeEach(func() {
+				globalsInteractor = nil
+			})
+
+			It("fails", func() {
+				Expect(err).To(MatchError("the artifact has globals, which cannot be restored with this adapter"))
+			})
+		})
+
+		Context("when the manifest does not match its checksum", func() {
+			BeforeEach(func() {
+				manifestPath := filepath.Join(artifactDirectory, "databases.json")
+				writeChecksumFile(manifestPath, fmt.Sprintf("%x", sha256.Sum256([]byte(`{"databases": ["ccdb"]}`))))
+			})
+
+			It("fails without restoring anything", func() {
+				Expect(err).To(MatchError(ContainSubstring("checksum of '" + filepath.Join(artifactDirectory, "databases.json") + "' does not match")))
+				Expect(globalsInteractor.ActionCallCount()).To(Equal(0))
+				Expect(databaseManager.CreateDatabaseCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the manifest has no checksum and checksums are required", func() {
+			BeforeEach(func() {
+				requireChecksum = true
+			})
+
+			It("fails without restoring anything", func() {
+				Expect(err).To(MatchError("no checksum file found for '" + filepath.Join(artifactDirectory, "databases.json") + "'"))
+				Expect(globalsInteractor.ActionCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when there is no manifest", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(artifactDirectory, "databases.json"))).To(Succeed())
+			})
+
+			It("fails", func() {
+				Expect(err).To(MatchError(ContainSubstring("could not read the manifest of the databases")))
+			})
+		})
+	})
+})
+
+// globals avoids passing a nil fake as a non-nil Interactor
+func globals(globalsInteractor *fakes.FakeInteractor) database.Interactor {
+	if globalsInteractor == nil {
+		return nil
+	}
+	return globalsInteractor
+}
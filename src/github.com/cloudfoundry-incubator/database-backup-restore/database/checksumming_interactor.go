@@ -0,0 +1,40 @@
+package database
+
+import "github.com/cloudfoundry-incubator/sha256sum"
+
+type ChecksumWritingInteractor struct {
+	interactor Interactor
+}
+
+func NewChecksumWritingInteractor(interactor Interactor) ChecksumWritingInteractor {
+	return ChecksumWritingInteractor{interactor: interactor}
+}
+
+func (i ChecksumWritingInteractor) Action(artifactFilePath string) error {
+	err := i.interactor.Action(artifactFilePath)
+	if err != nil {
+		return err
+	}
+
+	return sha256sum.Write(artifactFilePath)
+}
+
+type ChecksumVerifyingInteractor struct {
+	interactor Interactor
+	required   bool
+}
+
+// Artifacts without a checksum file, such as backups taken before checksums
+// were introduced, are restored without verifying them unless required is set.
+func NewChecksumVerifyingInteractor(interactor Interactor, required bool) ChecksumVerifyingInteractor {
+	return ChecksumVerifyingInteractor{interactor: interactor, required: required}
+}
+
+func (i ChecksumVerifyingInteractor) Action(artifactFilePath string) error {
+	err := sha256sum.Verify(artifactFilePath, i.required)
+	if err != nil {
+		return err
+	}
+
+	return i.interactor.Action(artifactFilePath)
+}
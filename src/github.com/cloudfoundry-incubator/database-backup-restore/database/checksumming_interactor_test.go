@@ -0,0 +1,134 @@
+package database_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/database"
+	"github.com/cloudfoundry-incubator/database-backup-restore/database/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checksumming interactors", func() {
+	var wrappedInteractor *fakes.FakeInteractor
+	var artifactDir string
+	var artifactPath string
+	var err error
+
+	BeforeEach(func() {
+		wrappedInteractor = new(fakes.FakeInteractor)
+		artifactDir, err = ioutil.TempDir("", "checksum_test_")
+		Expect(err).NotTo(HaveOccurred())
+		artifactPath = filepath.Join(artifactDir, "db.sql")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(artifactDir)
+	})
+
+	Describe("ChecksumWritingInteractor", func() {
+		JustBeforeEach(func() {
+			err = database.NewChecksumWritingInteractor(wrappedInteractor).Action(artifactPath)
+		})
+
+		Context("when the wrapped interactor succeeds", func() {
+			BeforeEach(func() {
+				wrappedInteractor.ActionStub = func(artifactFilePath string) error {
+					return ioutil.WriteFile(artifactFilePath, []byte("SOME BACKUP SQL"), 0666)
+				}
+			})
+
+			It("writes a checksum of the artifact next to it", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(wrappedInteractor.ActionArgsForCall(0)).To(Equal(artifactPath))
+
+				checksum, err := ioutil.ReadFile(artifactPath + ".sha256")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(checksum)).To(Equal(
+					fmt.Sprintf("%x  db.sql\n", sha256.Sum256([]byte("SOME BACKUP SQL")))))
+			})
+
+			It("makes the checksum readable only by its owner", func() {
+				info, err := os.Stat(artifactPath + ".sha256")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			})
+		})
+
+		Context("when the wrapped interactor fails", func() {
+			BeforeEach(func() {
+				wrappedInteractor.ActionReturns(fmt.Errorf("dump failed"))
+			})
+
+			It("returns its error without writing a checksum", func() {
+				Expect(err).To(MatchError("dump failed"))
+				Expect(artifactPath + ".sha256").NotTo(BeAnExistingFile())
+			})
+		})
+	})
+
+	Describe("ChecksumVerifyingInteractor", func() {
+		var required bool
+
+		BeforeEach(func() {
+			required = false
+			Expect(ioutil.WriteFile(artifactPath, []byte("SOME BACKUP SQL"), 0666)).To(Succeed())
+		})
+
+		JustBeforeEach(func() {
+			err = database.NewChecksumVerifyingInteractor(wrappedInteractor, required).Action(artifactPath)
+		})
+
+		Context("when the artifact matches its checksum", func() {
+			BeforeEach(func() {
+				writeChecksumFile(artifactPath, fmt.Sprintf("%x", sha256.Sum256([]byte("SOME BACKUP SQL"))))
+				wrappedInteractor.ActionReturns(fmt.Errorf("test error"))
+			})
+
+			It("delegates to the wrapped interactor", func() {
+				Expect(wrappedInteractor.ActionCallCount()).To(Equal(1))
+				Expect(wrappedInteractor.ActionArgsForCall(0)).To(Equal(artifactPath))
+				Expect(err).To(MatchError("test error"))
+			})
+		})
+
+		Context("when the artifact does not match its checksum", func() {
+			BeforeEach(func() {
+				writeChecksumFile(artifactPath, fmt.Sprintf("%x", sha256.Sum256([]byte("OTHER BACKUP SQL"))))
+			})
+
+			It("fails without calling the wrapped interactor", func() {
+				Expect(wrappedInteractor.ActionCallCount()).To(Equal(0))
+				Expect(err).To(MatchError(ContainSubstring(
+					fmt.Sprintf("checksum of '%s' does not match", artifactPath))))
+			})
+		})
+
+		Context("when the artifact has no checksum", func() {
+			It("delegates to the wrapped interactor", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(wrappedInteractor.ActionCallCount()).To(Equal(1))
+			})
+
+			Context("and checksums are required", func() {
+				BeforeEach(func() {
+					required = true
+				})
+
+				It("fails without calling the wrapped interactor", func() {
+					Expect(wrappedInteractor.ActionCallCount()).To(Equal(0))
+					Expect(err).To(MatchError(fmt.Sprintf("no checksum file found for '%s'", artifactPath)))
+				})
+			})
+		})
+	})
+})
+
+func writeChecksumFile(artifactPath, checksum string) {
+	contents := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(artifactPath))
+	Expect(ioutil.WriteFile(artifactPath+".sha256", []byte(contents), 0666)).To(Succeed())
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// CompressingInteractor compresses the dump as it is written to the artifact.
+type CompressingInteractor struct {
+	interactor Interactor
+	compressor Compressor
+}
+
+func NewCompressingInteractor(interactor Interactor, compressor Compressor) CompressingInteractor {
+	return CompressingInteractor{interactor: interactor, compressor: compressor}
+}
+
+func (i CompressingInteractor) Action(artifactFilePath string) error {
+	actionErr, compressErr := actionThroughPipe(i.interactor, artifactFilePath, i.compressor.Compress)
+	if actionErr != nil {
+		return actionErr
+	}
+	if compressErr != nil {
+		return fmt.Errorf("could not compress artifact file: %s", compressErr.Error())
+	}
+
+	return nil
+}
+
+// DecompressingInteractor decompresses the artifact as it is restored.
+// Uncompressed artifacts are passed to the wrapped interactor as they are.
+type DecompressingInteractor struct {
+	interactor Interactor
+}
+
+func NewDecompressingInteractor(interactor Interactor) DecompressingInteractor {
+	return DecompressingInteractor{interactor: interactor}
+}
+
+func (i DecompressingInteractor) Action(artifactFilePath string) error {
+	artifactFile, err := os.Open(artifactFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read artifact file: %s", err.Error())
+	}
+
+	header := make([]byte, len(zstdMagic))
+	n, err := io.ReadFull(artifactFile, header)
+	artifactFile.Close()
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("could not read artifact file: %s", err.Error())
+	}
+
+	compressor := detectCompressor(header[:n])
+	if compressor == nil {
+		return i.interactor.Action(artifactFilePath)
+	}
+
+	actionErr, decompressErr := actionFromPipe(i.interactor, artifactFilePath, compressor.Decompress)
+	if decompressErr != nil {
+		return fmt.Errorf("could not decompress artifact file: %s", decompressErr.Error())
+	}
+
+	return actionErr
+}
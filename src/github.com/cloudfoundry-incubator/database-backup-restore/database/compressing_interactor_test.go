@@ -0,0 +1,259 @@
+package database_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/database"
+	"github.com/cloudfoundry-incubator/database-backup-restore/database/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compressing interactors", func() {
+	var wrappedInteractor *fakes.FakeInteractor
+	var artifactDir string
+	var artifactPath string
+	var err error
+
+	BeforeEach(func() {
+		wrappedInteractor = new(fakes.FakeInteractor)
+		artifactDir, err = ioutil.TempDir("", "compression_test_")
+		Expect(err).NotTo(HaveOccurred())
+		artifactPath = filepath.Join(artifactDir, "db.sql")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(artifactDir)
+	})
+
+	Describe("NewCompressor", func() {
+		It("supports gzip", func() {
+			compressor, err := database.NewCompressor("gzip")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(compressor).To(Equal(database.GzipCompressor{}))
+		})
+
+		It("supports zstd", func() {
+			compressor, err := database.NewCompressor("zstd")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(compressor).To(Equal(database.ZstdCompressor{}))
+		})
+
+		It("returns no compressor when compression is not configured", func() {
+			compressor, err := database.NewCompressor("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(compressor).To(BeNil())
+		})
+
+		It("rejects other compressions", func() {
+			_, err := database.NewCompressor("bzip2")
+			Expect(err).To(MatchError("unsupported compression: bzip2"))
+		})
+	})
+
+	Describe("CompressingInteractor", func() {
+		var compressor database.Compressor
+		var dumpedFilePath string
+
+		BeforeEach(func() {
+			compressor = database.GzipCompressor{}
+		})
+
+		JustBeforeEach(func() {
+			err = database.NewCompressingInteractor(wrappedInteractor, compressor).Action(artifactPath)
+		})
+
+		Context("when the wrapped interactor dumps the database", func() {
+			BeforeEach(func() {
+				wrappedInteractor.ActionStub = func(artifactFilePath string) error {
+					dumpedFilePath = artifactFilePath
+					return ioutil.WriteFile(artifactFilePath, []byte("SOME BACKUP SQL"), 0600)
+				}
+			})
+
+			It("streams the dump into a compressed artifact", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dumpedFilePath).NotTo(Equal(artifactPath))
+
+				Expect(decompress(database.GzipCompressor{}, artifactPath)).To(Equal("SOME BACKUP SQL"))
+			})
+
+			Context("and the compression is zstd", func() {
+				BeforeEach(func() {
+					compressor = database.ZstdCompressor{}
+				})
+
+				It("streams the dump into a zstd compressed artifact", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(decompress(database.ZstdCompressor{}, artifactPath)).To(Equal("SOME BACKUP SQL"))
+				})
+			})
+
+			It("cleans up the pipe", func() {
+				Expect(dumpedFilePath).NotTo(BeAnExistingFile())
+				files, err := ioutil.ReadDir(artifactDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(HaveLen(1))
+			})
+		})
+
+		Context("when the wrapped interactor fails before dumping anything", func() {
+			BeforeEach(func() {
+				wrappedInteractor.ActionReturns(fmt.Errorf("version mismatch"))
+			})
+
+			It("returns its error", func() {
+				Expect(err).To(MatchError("version mismatch"))
+			})
+		})
+	})
+
+	Describe("DecompressingInteractor", func() {
+		var restoredContents string
+		var restoredFileMode os.FileMode
+
+		BeforeEach(func() {
+			restoredContents = ""
+			wrappedInteractor.ActionStub = func(artifactFilePath string) error {
+				info, err := os.Stat(artifactFilePath)
+				if err != nil {
+					return err
+				}
+				restoredFileMode = info.Mode()
+
+				contents, err := ioutil.ReadFile(artifactFilePath)
+				restoredContents = string(contents)
+				return err
+			}
+		})
+
+		JustBeforeEach(func() {
+			err = database.NewDecompressingInteractor(wrappedInteractor).Action(artifactPath)
+		})
+
+		Context("when the artifact is compressed", func() {
+			BeforeEach(func() {
+				compress(database.GzipCompressor{}, artifactPath, "SOME BACKUP SQL")
+			})
+
+			It("streams the decompressed dump through a pipe", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(restoredFileMode & os.ModeNamedPipe).NotTo(BeZero())
+				Expect(restoredContents).To(Equal("SOME BACKUP SQL"))
+			})
+
+			It("cleans up the pipe", func() {
+				Expect(wrappedInteractor.ActionArgsForCall(0)).NotTo(BeAnExistingFile())
+				files, err := ioutil.ReadDir(artifactDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(HaveLen(1))
+			})
+
+			Context("and the wrapped interactor reads the dump more than once", func() {
+				BeforeEach(func() {
+					wrappedInteractor.ActionStub = func(artifactFilePath string) error {
+						// like pg_restore, which lists the dump before restoring it
+						pipe, err := os.Open(artifactFilePath)
+						if err != nil {
+							return err
+						}
+						_, err = pipe.Read(make([]byte, 4))
+						pipe.Close()
+						if err != nil {
+							return err
+						}
+
+						contents, err := ioutil.ReadFile(artifactFilePath)
+						restoredContents = string(contents)
+						return err
+					}
+				})
+
+				It("streams the whole dump every time", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(restoredContents).To(Equal("SOME BACKUP SQL"))
+				})
+			})
+
+			Context("and the wrapped interactor never reads the dump", func() {
+				BeforeEach(func() {
+					wrappedInteractor.ActionStub = nil
+					wrappedInteractor.ActionReturns(fmt.Errorf("version mismatch"))
+				})
+
+				It("returns its error", func() {
+					Expect(err).To(MatchError("version mismatch"))
+				})
+			})
+		})
+
+		Context("when the artifact is compressed with zstd", func() {
+			BeforeEach(func() {
+				compress(database.ZstdCompressor{}, artifactPath, "SOME BACKUP SQL")
+			})
+
+			It("streams the decompressed dump through a pipe", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(restoredFileMode & os.ModeNamedPipe).NotTo(BeZero())
+				Expect(restoredContents).To(Equal("SOME BACKUP SQL"))
+			})
+		})
+
+		Context("when the zstd compressed artifact is truncated", func() {
+			BeforeEach(func() {
+				compress(database.ZstdCompressor{}, artifactPath, "SOME BACKUP SQL")
+				info, err := os.Stat(artifactPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(os.Truncate(artifactPath, info.Size()-1)).To(Succeed())
+			})
+
+			It("fails", func() {
+				Expect(err).To(MatchError(ContainSubstring("could not decompress artifact file")))
+			})
+		})
+
+		Context("when the compressed artifact is corrupt", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(artifactPath, []byte{0x1f, 0x8b, 0x00, 0x00}, 0600)).To(Succeed())
+			})
+
+			It("fails", func() {
+				Expect(err).To(MatchError(ContainSubstring("could not decompress artifact file")))
+			})
+		})
+
+		Context("when the artifact is not compressed", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(artifactPath, []byte("SOME BACKUP SQL"), 0600)).To(Succeed())
+			})
+
+			It("restores the artifact as it is", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(wrappedInteractor.ActionArgsForCall(0)).To(Equal(artifactPath))
+				Expect(restoredContents).To(Equal("SOME BACKUP SQL"))
+			})
+		})
+	})
+})
+
+func compress(compressor database.Compressor, artifactPath, contents string) {
+	artifactFile, err := os.Create(artifactPath)
+	Expect(err).NotTo(HaveOccurred())
+	defer artifactFile.Close()
+
+	Expect(compressor.Compress(artifactFile, bytes.NewBufferString(contents))).To(Succeed())
+}
+
+func decompress(compressor database.Compressor, artifactPath string) string {
+	artifactFile, err := os.Open(artifactPath)
+	Expect(err).NotTo(HaveOccurred())
+	defer artifactFile.Close()
+
+	contents := bytes.NewBuffer(nil)
+	Expect(compressor.Decompress(contents, artifactFile)).To(Succeed())
+	return contents.String()
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type Compressor interface {
+	Compress(dst io.Writer, src io.Reader) error
+	Decompress(dst io.Writer, src io.Reader) error
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// NewCompressor returns nil when no compression is configured.
+func NewCompressor(compression string) (Compressor, error) {
+	switch compression {
+	case "":
+		return nil, nil
+	case "gzip":
+		return GzipCompressor{}, nil
+	case "zstd":
+		return ZstdCompressor{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression: %s", compression)
+}
+
+// detectCompressor recognises a compressed artifact from its first bytes, and
+// returns nil for an uncompressed one.
+func detectCompressor(header []byte) Compressor {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return GzipCompressor{}
+	case bytes.HasPrefix(header, zstdMagic):
+		return ZstdCompressor{}
+	}
+
+	return nil
+}
+
+type GzipCompressor struct{}
+
+func (c GzipCompressor) Compress(dst io.Writer, src io.Reader) error {
+	writer := gzip.NewWriter(dst)
+	_, err := io.Copy(writer, src)
+	if err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (c GzipCompressor) Decompress(dst io.Writer, src io.Reader) error {
+	reader, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(dst, reader)
+	return err
+}
+
+type ZstdCompressor struct{}
+
+func (c ZstdCompressor) Compress(dst io.Writer, src io.Reader) error {
+	writer, err := zstd.NewWriter(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(writer, src)
+	if err != nil {
+		writer.Close()
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (c ZstdCompressor) Decompress(dst io.Writer, src io.Reader) error {
+	reader, err := zstd.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(dst, reader)
+	return err
+}
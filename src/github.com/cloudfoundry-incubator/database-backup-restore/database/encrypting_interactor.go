@@ -0,0 +1,88 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// EncryptingInteractor encrypts the dump as it is written to the artifact.
+type EncryptingInteractor struct {
+	interactor Interactor
+	encryptor  Encryptor
+}
+
+func NewEncryptingInteractor(interactor Interactor, encryptor Encryptor) EncryptingInteractor {
+	return EncryptingInteractor{interactor: interactor, encryptor: encryptor}
+}
+
+func (i EncryptingInteractor) Action(artifactFilePath string) error {
+	actionErr, encryptErr := actionThroughPipe(i.interactor, artifactFilePath, i.encryptor.Encrypt)
+	if actionErr != nil {
+		return actionErr
+	}
+	if encryptErr != nil {
+		return fmt.Errorf("could not encrypt artifact file: %s", encryptErr.Error())
+	}
+
+	return nil
+}
+
+// DecryptingInteractor decrypts the artifact as it is restored, after
+// decrypting it once to check the key and that the artifact is intact, so that
+// the restore isn't started on part of a dump. Unencrypted artifacts, from
+// before encryption was configured, are passed to the wrapped interactor as
+// they are.
+type DecryptingInteractor struct {
+	interactor Interactor
+	encryptor  *Encryptor
+}
+
+func NewDecryptingInteractor(interactor Interactor, encryptor *Encryptor) DecryptingInteractor {
+	return DecryptingInteractor{interactor: interactor, encryptor: encryptor}
+}
+
+func (i DecryptingInteractor) Action(artifactFilePath string) error {
+	artifactFile, err := os.Open(artifactFilePath)
+	if err != nil {
+		return fmt.Errorf("could not read artifact file: %s", err.Error())
+	}
+	defer artifactFile.Close()
+
+	header := make([]byte, len(encryptedMagic))
+	n, err := io.ReadFull(artifactFile, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("could not read artifact file: %s", err.Error())
+	}
+
+	if !isEncrypted(header[:n]) {
+		if i.encryptor != nil {
+			log.Printf("artifact file %s is not encrypted, restoring it as it is\n", artifactFilePath)
+		}
+		return i.interactor.Action(artifactFilePath)
+	}
+
+	if i.encryptor == nil {
+		return errors.New("artifact file is encrypted but no encryption_key is configured")
+	}
+
+	_, err = artifactFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("could not read artifact file: %s", err.Error())
+	}
+
+	err = i.encryptor.Decrypt(ioutil.Discard, artifactFile)
+	if err != nil {
+		return fmt.Errorf("could not decrypt artifact file: %s", err.Error())
+	}
+
+	actionErr, decryptErr := actionFromPipe(i.interactor, artifactFilePath, i.encryptor.Decrypt)
+	if decryptErr != nil {
+		return fmt.Errorf("could not decrypt artifact file: %s", decryptErr.Error())
+	}
+
+	return actionErr
+}
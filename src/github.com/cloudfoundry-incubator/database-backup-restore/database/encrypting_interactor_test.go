@@ -0,0 +1,260 @@
+package database_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/database"
+	"github.com/cloudfoundry-incubator/database-backup-restore/database/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Encrypting interactors", func() {
+	var wrappedInteractor *fakes.FakeInteractor
+	var encryptor database.Encryptor
+	var artifactDir string
+	var artifactPath string
+	var err error
+
+	BeforeEach(func() {
+		wrappedInteractor = new(fakes.FakeInteractor)
+		encryptor = database.NewEncryptor("correct horse battery staple")
+		artifactDir, err = ioutil.TempDir("", "encryption_test_")
+		Expect(err).NotTo(HaveOccurred())
+		artifactPath = filepath.Join(artifactDir, "db.sql")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(artifactDir)
+	})
+
+	Describe("Encryptor", func() {
+		var plaintext []byte
+		var encrypted *bytes.Buffer
+
+		BeforeEach(func() {
+			plaintext = make([]byte, 200*1024+17)
+			_, err := rand.Read(plaintext)
+			Expect(err).NotTo(HaveOccurred())
+
+			encrypted = bytes.NewBuffer(nil)
+			Expect(encryptor.Encrypt(encrypted, bytes.NewReader(plaintext))).To(Succeed())
+		})
+
+		It("decrypts what it encrypted", func() {
+			decrypted := bytes.NewBuffer(nil)
+			Expect(encryptor.Decrypt(decrypted, encrypted)).To(Succeed())
+			Expect(decrypted.Bytes()).To(Equal(plaintext))
+		})
+
+		It("does not leave the plaintext readable", func() {
+			Expect(bytes.Contains(encrypted.Bytes(), plaintext[:64])).To(BeFalse())
+		})
+
+		It("decrypts empty artifacts", func() {
+			encrypted := bytes.NewBuffer(nil)
+			Expect(encryptor.Encrypt(encrypted, bytes.NewReader(nil))).To(Succeed())
+
+			decrypted := bytes.NewBuffer(nil)
+			Expect(encryptor.Decrypt(decrypted, encrypted)).To(Succeed())
+			Expect(decrypted.Len()).To(Equal(0))
+		})
+
+		It("fails to decrypt with the wrong key", func() {
+			err := database.NewEncryptor("wrong").Decrypt(ioutil.Discard, encrypted)
+			Expect(err).To(MatchError("could not decrypt artifact: the encryption key is wrong or the artifact is corrupt"))
+		})
+
+		It("fails to decrypt a truncated artifact", func() {
+			truncated := bytes.NewReader(encrypted.Bytes()[:encrypted.Len()-100])
+			Expect(encryptor.Decrypt(ioutil.Discard, truncated)).To(MatchError("artifact is truncated"))
+		})
+
+		It("fails to decrypt an artifact with its last frame removed", func() {
+			frameLength := 5 + 64*1024 + 16
+			truncated := bytes.NewReader(encrypted.Bytes()[:8+1+16+3*frameLength])
+			Expect(encryptor.Decrypt(ioutil.Discard, truncated)).To(MatchError("artifact is truncated"))
+		})
+
+		It("fails to decrypt an artifact with data after its last frame", func() {
+			extended := bytes.NewReader(append(encrypted.Bytes(), "SOME MORE SQL"...))
+			Expect(encryptor.Decrypt(ioutil.Discard, extended)).To(MatchError("artifact is corrupt: it has data after its last frame"))
+		})
+
+		It("fails to decrypt an artifact with an unsupported format version", func() {
+			unsupported := encrypted.Bytes()
+			unsupported[8] = 2
+			err := encryptor.Decrypt(ioutil.Discard, bytes.NewReader(unsupported))
+			Expect(err).To(MatchError("artifact is encrypted with unsupported format version 2"))
+		})
+
+		It("fails to decrypt a tampered artifact", func() {
+			tampered := encrypted.Bytes()
+			tampered[len(tampered)-1] ^= 1
+			err := encryptor.Decrypt(ioutil.Discard, bytes.NewReader(tampered))
+			Expect(err).To(MatchError("could not decrypt artifact: the encryption key is wrong or the artifact is corrupt"))
+		})
+	})
+
+	Describe("EncryptingInteractor", func() {
+		var dumpedFilePath string
+
+		JustBeforeEach(func() {
+			err = database.NewEncryptingInteractor(wrappedInteractor, encryptor).Action(artifactPath)
+		})
+
+		Context("when the wrapped interactor dumps the database", func() {
+			BeforeEach(func() {
+				wrappedInteractor.ActionStub = func(artifactFilePath string) error {
+					dumpedFilePath = artifactFilePath
+					return ioutil.WriteFile(artifactFilePath, []byte("SOME BACKUP SQL"), 0600)
+				}
+			})
+
+			It("streams the dump into an encrypted artifact", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(dumpedFilePath).NotTo(Equal(artifactPath))
+
+				contents, err := ioutil.ReadFile(artifactPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(contents)).To(HavePrefix("BBRDBENC"))
+				Expect(string(contents)).NotTo(ContainSubstring("SOME BACKUP SQL"))
+			})
+		})
+
+		Context("when the wrapped interactor fails", func() {
+			BeforeEach(func() {
+				wrappedInteractor.ActionReturns(fmt.Errorf("version mismatch"))
+			})
+
+			It("returns its error", func() {
+				Expect(err).To(MatchError("version mismatch"))
+			})
+		})
+
+		Context("when the dump is also compressed", func() {
+			BeforeEach(func() {
+				wrappedInteractor.ActionStub = func(artifactFilePath string) error {
+					return ioutil.WriteFile(artifactFilePath, []byte("SOME BACKUP SQL"), 0600)
+				}
+			})
+
+			It("can be decrypted and decompressed", func() {
+				compressingInteractor := database.NewCompressingInteractor(wrappedInteractor, database.GzipCompressor{})
+				Expect(database.NewEncryptingInteractor(compressingInteractor, encryptor).Action(artifactPath)).To(Succeed())
+
+				restoringInteractor := new(fakes.FakeInteractor)
+				var restoredContents []byte
+				restoringInteractor.ActionStub = func(artifactFilePath string) error {
+					restoredContents, err = ioutil.ReadFile(artifactFilePath)
+					return err
+				}
+				decompressingInteractor := database.NewDecompressingInteractor(restoringInteractor)
+				Expect(database.NewDecryptingInteractor(decompressingInteractor, &encryptor).Action(artifactPath)).To(Succeed())
+				Expect(string(restoredContents)).To(Equal("SOME BACKUP SQL"))
+			})
+		})
+	})
+
+	Describe("DecryptingInteractor", func() {
+		var configuredEncryptor *database.Encryptor
+		var restoredContents string
+		var restoredFileMode os.FileMode
+
+		BeforeEach(func() {
+			configuredEncryptor = &encryptor
+			restoredContents = ""
+			wrappedInteractor.ActionStub = func(artifactFilePath string) error {
+				info, err := os.Stat(artifactFilePath)
+				if err != nil {
+					return err
+				}
+				restoredFileMode = info.Mode()
+
+				contents, err := ioutil.ReadFile(artifactFilePath)
+				restoredContents = string(contents)
+				return err
+			}
+		})
+
+		JustBeforeEach(func() {
+			err = database.NewDecryptingInteractor(wrappedInteractor, configuredEncryptor).Action(artifactPath)
+		})
+
+		Context("when the artifact is encrypted", func() {
+			BeforeEach(func() {
+				artifactFile, err := os.Create(artifactPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(encryptor.Encrypt(artifactFile, bytes.NewBufferString("SOME BACKUP SQL"))).To(Succeed())
+				Expect(artifactFile.Close()).To(Succeed())
+			})
+
+			It("streams the decrypted dump through a pipe", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(restoredFileMode & os.ModeNamedPipe).NotTo(BeZero())
+				Expect(restoredContents).To(Equal("SOME BACKUP SQL"))
+			})
+
+			It("cleans up the pipe", func() {
+				Expect(wrappedInteractor.ActionArgsForCall(0)).NotTo(BeAnExistingFile())
+				files, err := ioutil.ReadDir(artifactDir)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(HaveLen(1))
+			})
+
+			Context("and no encryption key is configured", func() {
+				BeforeEach(func() {
+					configuredEncryptor = nil
+				})
+
+				It("fails without calling the wrapped interactor", func() {
+					Expect(err).To(MatchError("artifact file is encrypted but no encryption_key is configured"))
+					Expect(wrappedInteractor.ActionCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("and the encryption key is wrong", func() {
+				BeforeEach(func() {
+					wrongEncryptor := database.NewEncryptor("wrong")
+					configuredEncryptor = &wrongEncryptor
+				})
+
+				It("fails without calling the wrapped interactor", func() {
+					Expect(err).To(MatchError("could not decrypt artifact file: could not decrypt artifact: " +
+						"the encryption key is wrong or the artifact is corrupt"))
+					Expect(wrappedInteractor.ActionCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the encrypted artifact is truncated", func() {
+			BeforeEach(func() {
+				encrypted := bytes.NewBuffer(nil)
+				Expect(encryptor.Encrypt(encrypted, bytes.NewReader(make([]byte, 200*1024)))).To(Succeed())
+				Expect(ioutil.WriteFile(artifactPath, encrypted.Bytes()[:100*1024], 0600)).To(Succeed())
+			})
+
+			It("fails without calling the wrapped interactor", func() {
+				Expect(err).To(MatchError("could not decrypt artifact file: artifact is truncated"))
+				Expect(wrappedInteractor.ActionCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the artifact is not encrypted", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(artifactPath, []byte("SOME BACKUP SQL"), 0600)).To(Succeed())
+			})
+
+			It("restores the artifact as it is", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(wrappedInteractor.ActionArgsForCall(0)).To(Equal(artifactPath))
+				Expect(restoredContents).To(Equal("SOME BACKUP SQL"))
+			})
+		})
+	})
+})
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// An encrypted artifact starts with encryptedMagic, the format version and a
+// random salt, and is followed by frames of up to encryptionChunkSize bytes of plaintext, each
+// sealed with AES-256-GCM. A frame is a flag byte, which is set on the last
+// frame, the length of the sealed chunk and the sealed chunk itself. The nonce
+// of a frame is its flag and its position in the artifact, so frames can't be
+// reordered, and the artifact can't be truncated or extended without failing
+// to decrypt.
+var encryptedMagic = []byte("BBRDBENC")
+
+const (
+	encryptionFormatVersion  = 1
+	encryptionSaltSize       = 16
+	encryptionKeyIterations  = 100000
+	encryptionChunkSize      = 64 * 1024
+	encryptionFrameFlagFinal = 1
+)
+
+type Encryptor struct {
+	passphrase string
+}
+
+func NewEncryptor(passphrase string) Encryptor {
+	return Encryptor{passphrase: passphrase}
+}
+
+func (e Encryptor) Encrypt(dst io.Writer, src io.Reader) error {
+	salt := make([]byte, encryptionSaltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return err
+	}
+
+	aead, err := e.newAEAD(salt)
+	if err != nil {
+		return err
+	}
+
+	_, err = dst.Write(append(append(append([]byte{}, encryptedMagic...), encryptionFormatVersion), salt...))
+	if err != nil {
+		return err
+	}
+
+	chunk := make([]byte, encryptionChunkSize)
+	next := make([]byte, encryptionChunkSize)
+	n, err := io.ReadFull(src, chunk)
+	for counter := uint64(0); ; counter++ {
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return err
+		}
+
+		var flag byte
+		var nextN int
+		if err == nil {
+			nextN, err = io.ReadFull(src, next)
+			if err == io.EOF {
+				flag = encryptionFrameFlagFinal
+			}
+		} else {
+			flag = encryptionFrameFlagFinal
+		}
+
+		sealed := aead.Seal(nil, frameNonce(flag, counter, aead.NonceSize()), chunk[:n], nil)
+		header := make([]byte, 5)
+		header[0] = flag
+		binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+		_, writeErr := dst.Write(append(header, sealed...))
+		if writeErr != nil {
+			return writeErr
+		}
+
+		if flag == encryptionFrameFlagFinal {
+			return nil
+		}
+
+		chunk, next = next, chunk
+		n = nextN
+	}
+}
+
+func (e Encryptor) Decrypt(dst io.Writer, src io.Reader) error {
+	header := make([]byte, len(encryptedMagic)+1+encryptionSaltSize)
+	_, err := io.ReadFull(src, header)
+	if err != nil {
+		return errors.New("artifact is not encrypted")
+	}
+	if !bytes.Equal(header[:len(encryptedMagic)], encryptedMagic) {
+		return errors.New("artifact is not encrypted")
+	}
+	if version := header[len(encryptedMagic)]; version != encryptionFormatVersion {
+		return fmt.Errorf("artifact is encrypted with unsupported format version %d", version)
+	}
+
+	aead, err := e.newAEAD(header[len(encryptedMagic)+1:])
+	if err != nil {
+		return err
+	}
+
+	frameHeader := make([]byte, 5)
+	for counter := uint64(0); ; counter++ {
+		_, err = io.ReadFull(src, frameHeader)
+		if err != nil {
+			return errors.New("artifact is truncated")
+		}
+
+		flag := frameHeader[0]
+		length := binary.BigEndian.Uint32(frameHeader[1:])
+		if length > encryptionChunkSize+uint32(aead.Overhead()) {
+			return errors.New("artifact is corrupt")
+		}
+
+		sealed := make([]byte, length)
+		_, err = io.ReadFull(src, sealed)
+		if err != nil {
+			return errors.New("artifact is truncated")
+		}
+
+		plaintext, err := aead.Open(nil, frameNonce(flag, counter, aead.NonceSize()), sealed, nil)
+		if err != nil {
+			return errors.New("could not decrypt artifact: the encryption key is wrong or the artifact is corrupt")
+		}
+
+		_, err = dst.Write(plaintext)
+		if err != nil {
+			return err
+		}
+
+		if flag == encryptionFrameFlagFinal {
+			break
+		}
+	}
+
+	_, err = io.ReadFull(src, make([]byte, 1))
+	if err == nil {
+		return errors.New("artifact is corrupt: it has data after its last frame")
+	}
+	if err != io.EOF {
+		return err
+	}
+
+	return nil
+}
+
+func (e Encryptor) newAEAD(salt []byte) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(e.passphrase), salt, encryptionKeyIterations, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// the key is unique to every artifact, as the salt is random, so a counter
+// is enough to make every nonce unique
+func frameNonce(flag byte, counter uint64, size int) []byte {
+	nonce := make([]byte, size)
+	nonce[0] = flag
+	binary.BigEndian.PutUint64(nonce[size-8:], counter)
+	return nonce
+}
+
+func isEncrypted(header []byte) bool {
+	return bytes.HasPrefix(header, encryptedMagic)
+}
@@ -20,6 +20,12 @@ type DumpUtilityVersionDetector interface {
 	GetVersion() (version.SemanticVersion, error)
 }
 
+//go:generate counterfeiter -o fakes/fake_database_manager.go . DatabaseManager
+type DatabaseManager interface {
+	ListDatabases() ([]string, error)
+	CreateDatabase(name string) error
+}
+
 type Factory interface {
 	Make(Action, config.ConnectionConfig) Interactor
 }
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/cloudfoundry-incubator/database-backup-restore/config"
@@ -39,6 +40,35 @@ func (f InteractorFactory) Make(action Action, config config.ConnectionConfig) (
 	return nil, fmt.Errorf("unsupported adapter/action combination: %s/%s", config.Adapter, action)
 }
 
+func (f InteractorFactory) MakeDatabaseManager(config config.ConnectionConfig) (DatabaseManager, error) {
+	switch config.Adapter {
+	case "postgres":
+		return postgres.NewDatabaseManager(config, f.utilitiesConfig.Postgres96.Client), nil
+	case "mysql":
+		return mysql.NewDatabaseManager(config, f.utilitiesConfig.Mysql.Client), nil
+	}
+
+	return nil, fmt.Errorf("unsupported adapter: %s", config.Adapter)
+}
+
+// MakeGlobalsInteractor returns nil for adapters without globals, which
+// belong to the server rather than to a database.
+func (f InteractorFactory) MakeGlobalsInteractor(action Action, config config.ConnectionConfig) (Interactor, error) {
+	switch {
+	case config.Adapter == "postgres" && action == "backup":
+		if f.utilitiesConfig.PostgresDumpAll == "" {
+			return nil, errors.New("PG_DUMPALL_PATH must be set to back up all databases")
+		}
+		return postgres.NewGlobalsBackuper(config, f.utilitiesConfig.PostgresDumpAll), nil
+	case config.Adapter == "postgres" && action == "restore":
+		return postgres.NewGlobalsRestorer(config, f.utilitiesConfig.Postgres96.Client), nil
+	case config.Adapter == "mysql":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported adapter/action combination: %s/%s", config.Adapter, action)
+}
+
 func (f InteractorFactory) makeMysqlBackuper(config config.ConnectionConfig) Interactor {
 	return NewVersionSafeInteractor(
 		mysql.NewBackuper(config, f.utilitiesConfig.Mysql.Dump),
@@ -121,3 +121,66 @@ var _ = Describe("InteractorFactory", func() {
 		})
 	})
 })
+
+var _ = Describe("InteractorFactory for all databases", func() {
+	var utilitiesConfig config.UtilitiesConfig
+	var interactorFactory database.InteractorFactory
+
+	BeforeEach(func() {
+		utilitiesConfig = config.UtilitiesConfig{PostgresDumpAll: "/bin/pg_dumpall"}
+	})
+
+	JustBeforeEach(func() {
+		interactorFactory = database.NewInteractorFactory(utilitiesConfig, new(fakes.FakeServerVersionDetector))
+	})
+
+	Describe("MakeDatabaseManager", func() {
+		It("builds a postgres.DatabaseManager for postgres", func() {
+			databaseManager, err := interactorFactory.MakeDatabaseManager(config.ConnectionConfig{Adapter: "postgres"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseManager).To(BeAssignableToTypeOf(postgres.DatabaseManager{}))
+		})
+
+		It("builds a mysql.DatabaseManager for mysql", func() {
+			databaseManager, err := interactorFactory.MakeDatabaseManager(config.ConnectionConfig{Adapter: "mysql"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(databaseManager).To(BeAssignableToTypeOf(mysql.DatabaseManager{}))
+		})
+
+		It("fails for other adapters", func() {
+			_, err := interactorFactory.MakeDatabaseManager(config.ConnectionConfig{Adapter: "unsupported"})
+			Expect(err).To(MatchError("unsupported adapter: unsupported"))
+		})
+	})
+
+	Describe("MakeGlobalsInteractor", func() {
+		It("builds a postgres.GlobalsBackuper to back up postgres", func() {
+			interactor, err := interactorFactory.MakeGlobalsInteractor("backup", config.ConnectionConfig{Adapter: "postgres"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(interactor).To(BeAssignableToTypeOf(postgres.GlobalsBackuper{}))
+		})
+
+		It("builds a postgres.GlobalsRestorer to restore postgres", func() {
+			interactor, err := interactorFactory.MakeGlobalsInteractor("restore", config.ConnectionConfig{Adapter: "postgres"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(interactor).To(BeAssignableToTypeOf(postgres.GlobalsRestorer{}))
+		})
+
+		It("builds nothing for mysql, which has no globals", func() {
+			interactor, err := interactorFactory.MakeGlobalsInteractor("backup", config.ConnectionConfig{Adapter: "mysql"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(interactor).To(BeNil())
+		})
+
+		Context("when the path to pg_dumpall is not set", func() {
+			BeforeEach(func() {
+				utilitiesConfig = config.UtilitiesConfig{}
+			})
+
+			It("fails to back up postgres", func() {
+				_, err := interactorFactory.MakeGlobalsInteractor("backup", config.ConnectionConfig{Adapter: "postgres"})
+				Expect(err).To(MatchError("PG_DUMPALL_PATH must be set to back up all databases"))
+			})
+		})
+	})
+})
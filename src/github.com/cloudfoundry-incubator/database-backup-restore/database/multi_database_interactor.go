@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+type DatabaseInteractor struct {
+	Name       string
+	Interactor Interactor
+}
+
+// MultiDatabaseInteractor runs the interactor of every database against its
+// own file in the artifact directory. A failing database doesn't stop the
+// others, and all the failures are returned together.
+type MultiDatabaseInteractor struct {
+	interactors []DatabaseInteractor
+}
+
+func NewMultiDatabaseInteractor(interactors []DatabaseInteractor) MultiDatabaseInteractor {
+	return MultiDatabaseInteractor{interactors: interactors}
+}
+
+func (i MultiDatabaseInteractor) Action(artifactDirectoryPath string) error {
+	var failures []string
+	for _, interactor := range i.interactors {
+		err := interactor.Interactor.Action(databaseArtifactFilePath(artifactDirectoryPath, interactor.Name))
+		if err != nil {
+			log.Printf("database %s failed: %s\n", interactor.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %s", interactor.Name, err))
+		} else {
+			log.Printf("database %s succeeded\n", interactor.Name)
+		}
+	}
+
+	if len(failures) != 0 {
+		return fmt.Errorf("%d of %d databases failed:\n%s",
+			len(failures), len(i.interactors), strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
+
+// The file of a database is named after it, with databaseArtifactFileExtension
+// appended so that it can't have the name of another database's checksum.
+const databaseArtifactFileExtension = ".dump"
+
+func databaseArtifactFilePath(artifactDirectoryPath, databaseName string) string {
+	return filepath.Join(artifactDirectoryPath, databaseName+databaseArtifactFileExtension)
+}
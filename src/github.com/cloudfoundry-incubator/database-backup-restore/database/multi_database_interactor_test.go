@@ -0,0 +1,67 @@
+package database_test
+
+import (
+	"fmt"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/database"
+	"github.com/cloudfoundry-incubator/database-backup-restore/database/fakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MultiDatabaseInteractor", func() {
+	var ccdbInteractor *fakes.FakeInteractor
+	var uaadbInteractor *fakes.FakeInteractor
+	var diegoInteractor *fakes.FakeInteractor
+	var err error
+
+	BeforeEach(func() {
+		ccdbInteractor = new(fakes.FakeInteractor)
+		uaadbInteractor = new(fakes.FakeInteractor)
+		diegoInteractor = new(fakes.FakeInteractor)
+	})
+
+	JustBeforeEach(func() {
+		err = database.NewMultiDatabaseInteractor([]database.DatabaseInteractor{
+			{Name: "ccdb", Interactor: ccdbInteractor},
+			{Name: "uaadb", Interactor: uaadbInteractor},
+			{Name: "diego", Interactor: diegoInteractor},
+		}).Action("/artifacts")
+	})
+
+	It("runs the interactor of every database against its own file", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ccdbInteractor.ActionArgsForCall(0)).To(Equal("/artifacts/ccdb.dump"))
+		Expect(uaadbInteractor.ActionArgsForCall(0)).To(Equal("/artifacts/uaadb.dump"))
+		Expect(diegoInteractor.ActionArgsForCall(0)).To(Equal("/artifacts/diego.dump"))
+	})
+
+	Context("when a database is named like the checksum of another", func() {
+		It("does not run it against the checksum", func() {
+			checksumNamedInteractor := new(fakes.FakeInteractor)
+			err := database.NewMultiDatabaseInteractor([]database.DatabaseInteractor{
+				{Name: "ccdb", Interactor: ccdbInteractor},
+				{Name: "ccdb.dump.sha256", Interactor: checksumNamedInteractor},
+			}).Action("/artifacts")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(checksumNamedInteractor.ActionArgsForCall(0)).To(Equal("/artifacts/ccdb.dump.sha256.dump"))
+		})
+	})
+
+	Context("when some of the databases fail", func() {
+		BeforeEach(func() {
+			ccdbInteractor.ActionReturns(fmt.Errorf("ccdb is down"))
+			diegoInteractor.ActionReturns(fmt.Errorf("diego is down"))
+		})
+
+		It("still runs the other databases", func() {
+			Expect(uaadbInteractor.ActionCallCount()).To(Equal(1))
+			Expect(diegoInteractor.ActionCallCount()).To(Equal(1))
+		})
+
+		It("returns all the failures", func() {
+			Expect(err).To(MatchError("2 of 3 databases failed:\nccdb: ccdb is down\ndiego: diego is down"))
+		})
+	})
+})
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// actionThroughPipe gives the interactor a named pipe to dump to, and streams
+// whatever is written to it through the filter into the artifact. Only the
+// filtered dump is written to disk, so an uncompressed or plaintext dump never
+// lands there. Pipes can be chained, by running an interactor that itself
+// calls actionThroughPipe.
+func actionThroughPipe(interactor Interactor, artifactFilePath string, filter func(dst io.Writer, src io.Reader) error) (actionErr error, filterErr error) {
+	pipeDir, pipePath, err := makePipe(artifactFilePath)
+	if err != nil {
+		return err, nil
+	}
+	defer os.RemoveAll(pipeDir)
+
+	filtered := make(chan error, 1)
+	go func() {
+		filtered <- filterPipe(pipePath, artifactFilePath, filter)
+	}()
+
+	actionErr = interactor.Action(pipePath)
+	filterErr = waitForFilter(pipePath, filtered)
+	return actionErr, filterErr
+}
+
+// waitForFilter makes sure the filter finishes even if the dump never opened
+// the pipe, by opening it for writing once the filter is waiting to read from
+// it.
+func waitForFilter(pipePath string, filtered chan error) error {
+	for {
+		select {
+		case err := <-filtered:
+			return err
+		default:
+		}
+
+		pipe, err := os.OpenFile(pipePath, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			pipe.Close()
+			return <-filtered
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func filterPipe(pipePath, artifactFilePath string, filter func(dst io.Writer, src io.Reader) error) error {
+	pipe, err := os.Open(pipePath)
+	if err != nil {
+		return err
+	}
+	defer pipe.Close()
+
+	artifactFile, err := os.OpenFile(artifactFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		// keep reading, so that the dump isn't blocked on a full pipe
+		io.Copy(ioutil.Discard, pipe)
+		return err
+	}
+
+	err = filter(artifactFile, pipe)
+	if err != nil {
+		io.Copy(ioutil.Discard, pipe)
+		artifactFile.Close()
+		return err
+	}
+
+	return artifactFile.Close()
+}
+
+// actionFromPipe is the reverse of actionThroughPipe: it gives the interactor a
+// named pipe to restore from, and streams the artifact through the filter into
+// it. The artifact is streamed again every time the pipe is opened, as
+// pg_restore reads the dump twice.
+func actionFromPipe(interactor Interactor, artifactFilePath string, filter func(dst io.Writer, src io.Reader) error) (actionErr error, filterErr error) {
+	pipeDir, pipePath, err := makePipe(artifactFilePath)
+	if err != nil {
+		return err, nil
+	}
+	defer os.RemoveAll(pipeDir)
+
+	done := make(chan struct{})
+	fed := make(chan error, 1)
+	go func() {
+		fed <- feedPipe(pipePath, artifactFilePath, filter, done)
+	}()
+
+	actionErr = interactor.Action(pipePath)
+	close(done)
+	filterErr = waitForFeed(pipePath, fed)
+	return actionErr, filterErr
+}
+
+func makePipe(artifactFilePath string) (string, string, error) {
+	pipeDir, err := ioutil.TempDir(filepath.Dir(artifactFilePath), ".dump")
+	if err != nil {
+		return "", "", fmt.Errorf("could not create dump pipe: %s", err.Error())
+	}
+
+	pipePath := filepath.Join(pipeDir, "dump")
+	err = syscall.Mkfifo(pipePath, 0600)
+	if err != nil {
+		os.RemoveAll(pipeDir)
+		return "", "", fmt.Errorf("could not create dump pipe: %s", err.Error())
+	}
+
+	return pipeDir, pipePath, nil
+}
+
+// waitForFeed makes sure the feed finishes once the restore is done, by
+// opening the pipe for reading while the feed is waiting to write to it.
+func waitForFeed(pipePath string, fed chan error) error {
+	for {
+		select {
+		case err := <-fed:
+			return err
+		default:
+		}
+
+		pipe, err := os.OpenFile(pipePath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			pipe.Close()
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// feedPipe keeps streaming the artifact into the pipe until the restore is
+// done, even after it failed once, so that the restore is never left waiting
+// for the pipe to be opened. Every time the pipe is opened, it is replaced by a
+// new one, so that the next restore to open it only reads what is streamed
+// after it did, and this one reaches the end of the dump when it is closed.
+func feedPipe(pipePath, artifactFilePath string, filter func(dst io.Writer, src io.Reader) error, done chan struct{}) error {
+	var feedErr error
+	for {
+		pipe, err := os.OpenFile(pipePath, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case <-done:
+			pipe.Close()
+			return feedErr
+		default:
+		}
+
+		err = replacePipe(pipePath)
+		if err != nil {
+			pipe.Close()
+			return err
+		}
+
+		err = feedPipeOnce(pipe, artifactFilePath, filter)
+		pipe.Close()
+		if err != nil && feedErr == nil {
+			feedErr = err
+		}
+	}
+}
+
+func replacePipe(pipePath string) error {
+	newPipePath := pipePath + ".next"
+	err := syscall.Mkfifo(newPipePath, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(newPipePath, pipePath)
+}
+
+// feedPipeOnce streams the artifact into the pipe. The restore may stop
+// reading before the end, e.g. when pg_restore only lists the contents of the
+// dump, which is not an error.
+func feedPipeOnce(pipe io.Writer, artifactFilePath string, filter func(dst io.Writer, src io.Reader) error) error {
+	artifactFile, err := os.Open(artifactFilePath)
+	if err != nil {
+		return err
+	}
+	defer artifactFile.Close()
+
+	writer := &errorRecordingWriter{writer: pipe}
+	err = filter(writer, artifactFile)
+	if err != nil && writer.err == nil {
+		return err
+	}
+
+	return nil
+}
+
+type errorRecordingWriter struct {
+	writer io.Writer
+	err    error
+}
+
+func (w *errorRecordingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
@@ -28,8 +28,15 @@ func NewVersionSafeInteractor(
 }
 
 func (i VersionSafeInteractor) Action(artifactFilePath string) error {
-	dumpUtilityVersion, _ := i.dumpUtilityVersionDetector.GetVersion()
-	serverVersion, _ := i.serverVersionDetector.GetVersion(i.connectionConfig)
+	dumpUtilityVersion, err := i.dumpUtilityVersionDetector.GetVersion()
+	if err != nil {
+		return err
+	}
+
+	serverVersion, err := i.serverVersionDetector.GetVersion(i.connectionConfig)
+	if err != nil {
+		return err
+	}
 
 	if !serverVersion.MinorVersionMatches(dumpUtilityVersion) {
 		return fmt.Errorf("Version mismatch between dump utility %s and the database server %s\n"+
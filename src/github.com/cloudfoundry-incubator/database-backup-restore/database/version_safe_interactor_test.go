@@ -78,4 +78,37 @@ var _ = Describe("VersionSafeInteractor", func() {
 			Expect(err).To(MatchError(ContainSubstring("Version mismatch")))
 		})
 	})
+
+	Context("when the dump utility version cannot be detected", func() {
+		BeforeEach(func() {
+			dumpUtilityVersionDetector.GetVersionReturns(version.SemanticVersion{}, fmt.Errorf("could not run the dump utility"))
+		})
+
+		It("fails", func() {
+			err := versionSafeInteractor.Action("artifact/file/path")
+
+			By("not calling the wrapped interactor")
+			Expect(wrappedInteractor.ActionCallCount()).To(Equal(0))
+
+			By("returning the error")
+			Expect(err).To(MatchError("could not run the dump utility"))
+		})
+	})
+
+	Context("when the server version cannot be detected", func() {
+		BeforeEach(func() {
+			dumpUtilityVersionDetector.GetVersionReturns(version.ParseFromString("1.2.4"))
+			serverVersionDetector.GetVersionReturns(version.SemanticVersion{}, fmt.Errorf("could not connect to the server"))
+		})
+
+		It("fails", func() {
+			err := versionSafeInteractor.Action("artifact/file/path")
+
+			By("not calling the wrapped interactor")
+			Expect(wrappedInteractor.ActionCallCount()).To(Equal(0))
+
+			By("returning the error")
+			Expect(err).To(MatchError("could not connect to the server"))
+		})
+	})
 })
@@ -38,6 +38,7 @@ var fakePgDump96 *binmock.Mock
 var fakePgRestore94 *binmock.Mock
 var fakePgRestore96 *binmock.Mock
 var fakePgClient *binmock.Mock
+var fakePgDumpAll *binmock.Mock
 var fakeMysqlClient *binmock.Mock
 var fakeMysqlDump *binmock.Mock
 
@@ -52,6 +53,7 @@ var _ = BeforeSuite(func() {
 	fakePgDump96 = binmock.NewBinMock(Fail)
 	fakePgRestore94 = binmock.NewBinMock(Fail)
 	fakePgRestore96 = binmock.NewBinMock(Fail)
+	fakePgDumpAll = binmock.NewBinMock(Fail)
 	fakeMysqlDump = binmock.NewBinMock(Fail)
 	fakeMysqlClient = binmock.NewBinMock(Fail)
 
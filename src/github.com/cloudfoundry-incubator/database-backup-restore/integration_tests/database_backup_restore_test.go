@@ -25,6 +25,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cloudfoundry-incubator/sha256sum"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
@@ -78,7 +79,12 @@ var _ = Describe("Backup and Restore DB Utility", func() {
 			Entry("the artifact-file is not provided", TestEntry{
 				arguments:       "--backup --config %s",
 				configGenerator: validPgConfig,
-				expectedOutput:  "Missing --artifact-file flag",
+				expectedOutput:  "Missing --artifact-file or --artifact-directory flag",
+			}),
+			Entry("both the artifact-file and the artifact-directory are provided", TestEntry{
+				arguments:       "--backup --artifact-file /foo --artifact-directory /bar --config %s",
+				configGenerator: validPgConfig,
+				expectedOutput:  "Only one of: --artifact-file or --artifact-directory can be provided",
 			}),
 			Entry("is not a valid json", TestEntry{
 				arguments:       "--backup --artifact-file /foo --config %s",
@@ -95,6 +101,56 @@ var _ = Describe("Backup and Restore DB Utility", func() {
 				configGenerator: emptyTablesConfig,
 				expectedOutput:  "Tables specified but empty",
 			}),
+			Entry("both database and databases fields", TestEntry{
+				arguments:       "--backup --artifact-directory /foo --config %s",
+				configGenerator: databaseAndDatabasesConfig,
+				expectedOutput:  "Only one of: database, databases or all_databases can be specified",
+			}),
+			Entry("both database and all_databases fields", TestEntry{
+				arguments:       "--backup --artifact-directory /foo --config %s",
+				configGenerator: databaseAndAllDatabasesConfig,
+				expectedOutput:  "Only one of: database, databases or all_databases can be specified",
+			}),
+			Entry("all_databases with an artifact-file", TestEntry{
+				arguments:       "--backup --artifact-file /foo --config %s",
+				configGenerator: allDatabasesConfig,
+				expectedOutput:  "--artifact-directory must be provided when the config has several databases",
+			}),
+			Entry("empty list of databases field", TestEntry{
+				arguments:       "--backup --artifact-directory /foo --config %s",
+				configGenerator: emptyDatabasesConfig,
+				expectedOutput:  "Databases specified but empty",
+			}),
+			Entry("a database listed twice", TestEntry{
+				arguments:       "--backup --artifact-directory /foo --config %s",
+				configGenerator: duplicateDatabasesConfig,
+				expectedOutput:  "Database ccdb specified more than once",
+			}),
+			Entry("a database name that is a path", TestEntry{
+				arguments:       "--backup --artifact-directory /foo --config %s",
+				configGenerator: pathDatabaseNameConfig,
+				expectedOutput:  "Invalid database name '../ccdb'",
+			}),
+			Entry("databases with an artifact-file", TestEntry{
+				arguments:       "--backup --artifact-file /foo --config %s",
+				configGenerator: validDatabasesConfig,
+				expectedOutput:  "--artifact-directory must be provided when the config has several databases",
+			}),
+			Entry("tls with an unsupported verify_mode", TestEntry{
+				arguments:       "--backup --artifact-file /foo --config %s",
+				configGenerator: tlsWithUnsupportedVerifyModeConfig,
+				expectedOutput:  "Unsupported TLS verify_mode sometimes",
+			}),
+			Entry("tls with a client certificate but no private key", TestEntry{
+				arguments:       "--backup --artifact-file /foo --config %s",
+				configGenerator: tlsWithoutPrivateKeyConfig,
+				expectedOutput:  "TLS client certificate and private key must be provided together",
+			}),
+			Entry("unsupported compression", TestEntry{
+				arguments:       "--backup --artifact-file /foo --config %s",
+				configGenerator: invalidCompressionConfig,
+				expectedOutput:  "Unsupported compression bzip2",
+			}),
 		}
 
 		DescribeTable("raises the appropriate error when",
@@ -123,6 +179,12 @@ var _ = Describe("Backup and Restore DB Utility", func() {
 	})
 })
 
+func writeChecksums(filePaths ...string) {
+	for _, filePath := range filePaths {
+		Expect(sha256sum.Write(filePath)).To(Succeed())
+	}
+}
+
 func tempFilePath() string {
 	tmpfile, err := ioutil.TempFile("", "")
 	Expect(err).NotTo(HaveOccurred())
@@ -169,6 +231,62 @@ func emptyTablesConfig() (string, error) {
 	return validConfig.Name(), nil
 }
 
+func databaseAndDatabasesConfig() (string, error) {
+	return writeConfig(`{"adapter":"mysql","database":"ccdb","databases":[{"name":"uaadb"}]}`)
+}
+
+func databaseAndAllDatabasesConfig() (string, error) {
+	return writeConfig(`{"adapter":"postgres","database":"ccdb","all_databases":true}`)
+}
+
+func allDatabasesConfig() (string, error) {
+	return writeConfig(`{"adapter":"postgres","all_databases":true}`)
+}
+
+func emptyDatabasesConfig() (string, error) {
+	return writeConfig(`{"adapter":"mysql","databases":[]}`)
+}
+
+func duplicateDatabasesConfig() (string, error) {
+	return writeConfig(`{"adapter":"mysql","databases":[{"name":"ccdb"},{"name":"ccdb"}]}`)
+}
+
+func pathDatabaseNameConfig() (string, error) {
+	return writeConfig(`{"adapter":"mysql","databases":[{"name":"../ccdb"}]}`)
+}
+
+func validDatabasesConfig() (string, error) {
+	return writeConfig(`{"adapter":"mysql","databases":[{"name":"ccdb"},{"name":"uaadb"}]}`)
+}
+
+func tlsWithUnsupportedVerifyModeConfig() (string, error) {
+	return writeConfig(`{"adapter":"postgres","database":"ccdb","tls":{"verify_mode":"sometimes","cert":{}}}`)
+}
+
+func tlsWithoutPrivateKeyConfig() (string, error) {
+	return writeConfig(`{"adapter":"postgres","database":"ccdb","tls":{"cert":{"ca":"CA","certificate":"CERT"}}}`)
+}
+
+func writeConfig(contents string) (string, error) {
+	configFile, err := ioutil.TempFile(os.TempDir(), "")
+	if err != nil {
+		return "", err
+	}
+	defer configFile.Close()
+
+	_, err = fmt.Fprint(configFile, contents)
+	return configFile.Name(), err
+}
+
+func invalidCompressionConfig() (string, error) {
+	invalidCompressionConfig, err := ioutil.TempFile(os.TempDir(), "")
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprint(invalidCompressionConfig, `{"adapter":"mysql","compression":"bzip2"}`)
+	return invalidCompressionConfig.Name(), nil
+}
+
 func validPgConfig() (string, error) {
 	validConfig, err := ioutil.TempFile(os.TempDir(), "")
 	if err != nil {
@@ -197,6 +315,30 @@ type Config struct {
 	Database string   `json:"database"`
 	Adapter  string   `json:"adapter"`
 	Tables   []string `json:"tables,omitempty"`
+
+	Compression   string `json:"compression,omitempty"`
+	EncryptionKey string `json:"encryption_key,omitempty"`
+
+	Databases    []DatabaseConfig `json:"databases,omitempty"`
+	AllDatabases bool             `json:"all_databases,omitempty"`
+
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+type TLSConfig struct {
+	VerifyMode string        `json:"verify_mode,omitempty"`
+	Cert       TLSCertConfig `json:"cert"`
+}
+
+type TLSCertConfig struct {
+	CA          string `json:"ca,omitempty"`
+	Certificate string `json:"certificate,omitempty"`
+	PrivateKey  string `json:"private_key,omitempty"`
+}
+
+type DatabaseConfig struct {
+	Name   string   `json:"name"`
+	Tables []string `json:"tables,omitempty"`
 }
 
 func buildConfigFile(config Config) *os.File {
@@ -17,13 +17,18 @@
 package integration_tests
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"io/ioutil"
 	"log"
 	"os"
 
+	"github.com/cloudfoundry-incubator/database-backup-restore/database"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
@@ -108,6 +113,11 @@ var _ = Describe("MySQL", func() {
 
 					fakeMysqlDump.WhenCalled().WillExitWith(0)
 				})
+				It("writes a checksum next to the artifact", func() {
+					Expect(session).Should(gexec.Exit(0))
+					Expect(artifactFile + ".sha256").To(BeAnExistingFile())
+				})
+
 				It("calls mysqldump with the correct arguments", func() {
 					Expect(fakeMysqlDump.Invocations()).To(HaveLen(2))
 
@@ -134,6 +144,61 @@ var _ = Describe("MySQL", func() {
 					Expect(session).Should(gexec.Exit(0))
 				})
 
+				Context("when 'compression' is specified in the configFile", func() {
+					BeforeEach(func() {
+						configFile = buildConfigFile(Config{
+							Adapter:     "mysql",
+							Username:    username,
+							Password:    password,
+							Host:        host,
+							Port:        port,
+							Database:    databaseName,
+							Compression: "gzip",
+						})
+					})
+
+					It("compresses the artifact", func() {
+						Expect(session).Should(gexec.Exit(0))
+
+						contents, err := ioutil.ReadFile(artifactFile)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(contents).To(HavePrefix(string([]byte{0x1f, 0x8b})))
+					})
+
+					It("dumps to a pipe next to the artifact instead of the artifact itself", func() {
+						resultFileArg := fakeMysqlDump.Invocations()[1].Args()[6]
+						Expect(resultFileArg).To(HavePrefix("--result-file=" + filepath.Dir(artifactFile)))
+						Expect(resultFileArg).NotTo(Equal("--result-file=" + artifactFile))
+					})
+				})
+
+				Context("when 'encryption_key' is specified in the configFile", func() {
+					BeforeEach(func() {
+						configFile = buildConfigFile(Config{
+							Adapter:       "mysql",
+							Username:      username,
+							Password:      password,
+							Host:          host,
+							Port:          port,
+							Database:      databaseName,
+							EncryptionKey: "correct horse battery staple",
+						})
+					})
+
+					It("encrypts the artifact", func() {
+						Expect(session).Should(gexec.Exit(0))
+
+						contents, err := ioutil.ReadFile(artifactFile)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(string(contents)).To(HavePrefix("BBRDBENC"))
+					})
+
+					It("does not pass the encryption key to mysqldump", func() {
+						Expect(session).Should(gexec.Exit(0))
+						Expect(fakeMysqlDump.Invocations()[1].Args()).NotTo(ContainElement(ContainSubstring("correct horse")))
+					})
+				})
+
 				Context("when 'tables' are specified in the configFile", func() {
 					BeforeEach(func() {
 						configFile = buildConfigFile(Config{
@@ -168,6 +233,162 @@ var _ = Describe("MySQL", func() {
 					})
 				})
 			})
+			Context("when 'tls' is specified in the configFile", func() {
+				BeforeEach(func() {
+					configFile = buildConfigFile(Config{
+						Adapter:  "mysql",
+						Username: username,
+						Password: password,
+						Host:     host,
+						Port:     port,
+						Database: databaseName,
+						TLS: &TLSConfig{
+							Cert: TLSCertConfig{
+								CA:          "A_CA_CERT",
+								Certificate: "A_CLIENT_CERT",
+								PrivateKey:  "A_CLIENT_KEY",
+							},
+						},
+					})
+
+					fakeMysqlClient.WhenCalled().WillPrintToStdOut("10.1.24-MariaDB-wsrep")
+					fakeMysqlDump.WhenCalledWith("-V").
+						WillPrintToStdOut("mysqldump  Ver 10.16 Distrib 10.1.24-MariaDB, for Linux (x86_64)")
+					fakeMysqlDump.WhenCalled().WillExitWith(0)
+				})
+
+				It("connects over TLS, verifying the server's certificate and host", func() {
+					Expect(session).Should(gexec.Exit(0))
+
+					for _, args := range [][]string{
+						fakeMysqlClient.Invocations()[0].Args(),
+						fakeMysqlDump.Invocations()[1].Args(),
+					} {
+						Expect(args).To(ContainElement("--ssl"))
+						Expect(args).To(ContainElement(HavePrefix("--ssl-ca=")))
+						Expect(args).To(ContainElement("--ssl-verify-server-cert"))
+						Expect(args).To(ContainElement(HavePrefix("--ssl-cert=")))
+						Expect(args).To(ContainElement(HavePrefix("--ssl-key=")))
+					}
+				})
+
+				It("does not pass the certificates themselves on the command line", func() {
+					Expect(session).Should(gexec.Exit(0))
+					Expect(fakeMysqlDump.Invocations()[1].Args()).NotTo(ContainElement(ContainSubstring("A_CLIENT_KEY")))
+				})
+
+				Context("and 'verify_mode' is 'ca'", func() {
+					BeforeEach(func() {
+						configFile = buildConfigFile(Config{
+							Adapter:  "mysql",
+							Username: username,
+							Password: password,
+							Host:     host,
+							Port:     port,
+							Database: databaseName,
+							TLS: &TLSConfig{
+								VerifyMode: "ca",
+								Cert:       TLSCertConfig{CA: "A_CA_CERT"},
+							},
+						})
+					})
+
+					It("only verifies the server's certificate", func() {
+						Expect(session).Should(gexec.Exit(0))
+
+						args := fakeMysqlDump.Invocations()[1].Args()
+						Expect(args).To(ContainElement(HavePrefix("--ssl-ca=")))
+						Expect(args).NotTo(ContainElement("--ssl-verify-server-cert"))
+						Expect(args).NotTo(ContainElement(HavePrefix("--ssl-cert=")))
+					})
+				})
+
+				Context("and 'verify_mode' is 'none'", func() {
+					BeforeEach(func() {
+						configFile = buildConfigFile(Config{
+							Adapter:  "mysql",
+							Username: username,
+							Password: password,
+							Host:     host,
+							Port:     port,
+							Database: databaseName,
+							TLS: &TLSConfig{
+								VerifyMode: "none",
+								Cert:       TLSCertConfig{CA: "A_CA_CERT"},
+							},
+						})
+					})
+
+					It("connects over TLS without verifying the server's certificate", func() {
+						Expect(session).Should(gexec.Exit(0))
+
+						args := fakeMysqlDump.Invocations()[1].Args()
+						Expect(args).To(ContainElement("--ssl"))
+						Expect(args).NotTo(ContainElement(HavePrefix("--ssl-ca=")))
+						Expect(args).NotTo(ContainElement("--ssl-verify-server-cert"))
+					})
+				})
+
+				Context("and no CA is configured", func() {
+					BeforeEach(func() {
+						configFile = buildConfigFile(Config{
+							Adapter:  "mysql",
+							Username: username,
+							Password: password,
+							Host:     host,
+							Port:     port,
+							Database: databaseName,
+							TLS:      &TLSConfig{},
+						})
+					})
+
+					It("verifies the server's certificate against the system's CAs", func() {
+						Expect(session).Should(gexec.Exit(0))
+
+						args := fakeMysqlDump.Invocations()[1].Args()
+						Expect(args).To(ContainElement("--ssl-ca=/etc/ssl/certs/ca-certificates.crt"))
+						Expect(args).To(ContainElement("--ssl-verify-server-cert"))
+					})
+				})
+			})
+
+			Context("when the version of the server cannot be checked over TLS", func() {
+				BeforeEach(func() {
+					configFile = buildConfigFile(Config{
+						Adapter:  "mysql",
+						Username: username,
+						Password: password,
+						Host:     host,
+						Port:     port,
+						Database: databaseName,
+						TLS: &TLSConfig{
+							Cert: TLSCertConfig{
+								CA:          "A_CA_CERT",
+								Certificate: "A_CLIENT_CERT",
+								PrivateKey:  "A_CLIENT_KEY",
+							},
+						},
+					})
+
+					fakeMysqlClient.WhenCalled().WillExitWith(1)
+					fakeMysqlDump.WhenCalledWith("-V").
+						WillPrintToStdOut("mysqldump  Ver 10.16 Distrib 10.1.24-MariaDB, for Linux (x86_64)")
+				})
+
+				It("fails and removes the certificates", func() {
+					Expect(session).Should(gexec.Exit(1))
+
+					var sslKeyPath string
+					for _, arg := range fakeMysqlClient.Invocations()[0].Args() {
+						if strings.HasPrefix(arg, "--ssl-key=") {
+							sslKeyPath = strings.TrimPrefix(arg, "--ssl-key=")
+						}
+					}
+					Expect(sslKeyPath).NotTo(BeEmpty())
+					Expect(sslKeyPath).NotTo(BeAnExistingFile())
+				})
+			})
+
 			Context("when mysqldump fails", func() {
 				BeforeEach(func() {
 					fakeMysqlDump.WhenCalled().WillExitWith(1)
@@ -248,6 +469,299 @@ var _ = Describe("MySQL", func() {
 
 	})
 
+	Context("backup of several databases", func() {
+		var artifactDirectory string
+
+		BeforeEach(func() {
+			artifactDirectory, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			configFile = buildConfigFile(Config{
+				Adapter:  "mysql",
+				Username: username,
+				Password: password,
+				Host:     host,
+				Port:     port,
+				Databases: []DatabaseConfig{
+					{Name: "ccdb"},
+					{Name: "uaadb", Tables: []string{"users"}},
+				},
+			})
+
+			envVars["MYSQL_DUMP_PATH"] = fakeMysqlDump.Path
+			envVars["MYSQL_CLIENT_PATH"] = fakeMysqlClient.Path
+
+			for _, databaseName := range []string{"ccdb", "uaadb"} {
+				fakeMysqlClient.WhenCalledWith(
+					"--skip-column-names",
+					"--silent",
+					fmt.Sprintf("--user=%s", username),
+					fmt.Sprintf("--password=%s", password),
+					fmt.Sprintf("--host=%s", host),
+					fmt.Sprintf("--port=%d", port),
+					`--execute=SELECT VERSION()`,
+				).WillPrintToStdOut("10.1.24-MariaDB-wsrep")
+
+				// the fake mysqldump doesn't write the dump
+				artifactFile := filepath.Join(artifactDirectory, databaseName+".dump")
+				Expect(ioutil.WriteFile(artifactFile, []byte{}, 0600)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(artifactDirectory)
+		})
+
+		JustBeforeEach(func() {
+			cmd := exec.Command(
+				compiledSDKPath,
+				"--artifact-directory",
+				artifactDirectory,
+				"--config",
+				configFile.Name(),
+				"--backup")
+
+			for key, val := range envVars {
+				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
+			}
+
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit())
+		})
+
+		Context("when mysqldump succeeds", func() {
+			BeforeEach(func() {
+				fakeMysqlDump.WhenCalledWith("-V").
+					WillPrintToStdOut("mysqldump  Ver 10.16 Distrib 10.1.24-MariaDB, for Linux (x86_64)")
+				fakeMysqlDump.WhenCalled().WillExitWith(0)
+				fakeMysqlDump.WhenCalledWith("-V").
+					WillPrintToStdOut("mysqldump  Ver 10.16 Distrib 10.1.24-MariaDB, for Linux (x86_64)")
+				fakeMysqlDump.WhenCalled().WillExitWith(0)
+			})
+
+			It("dumps every database into its own file", func() {
+				Expect(session).Should(gexec.Exit(0))
+				Expect(fakeMysqlDump.Invocations()).To(HaveLen(4))
+
+				Expect(fakeMysqlDump.Invocations()[1].Args()).To(ContainElement(
+					fmt.Sprintf("--result-file=%s", filepath.Join(artifactDirectory, "ccdb.dump"))))
+				Expect(fakeMysqlDump.Invocations()[1].Args()).To(ContainElement("ccdb"))
+
+				Expect(fakeMysqlDump.Invocations()[3].Args()).To(ContainElement(
+					fmt.Sprintf("--result-file=%s", filepath.Join(artifactDirectory, "uaadb.dump"))))
+				Expect(fakeMysqlDump.Invocations()[3].Args()).To(ContainElement("uaadb"))
+				Expect(fakeMysqlDump.Invocations()[3].Args()).To(ContainElement("users"))
+			})
+
+			It("writes a checksum next to every file", func() {
+				Expect(session).Should(gexec.Exit(0))
+				Expect(filepath.Join(artifactDirectory, "ccdb.dump.sha256")).To(BeAnExistingFile())
+				Expect(filepath.Join(artifactDirectory, "uaadb.dump.sha256")).To(BeAnExistingFile())
+			})
+		})
+
+		Context("when mysqldump fails for one of the databases", func() {
+			BeforeEach(func() {
+				fakeMysqlDump.WhenCalledWith("-V").
+					WillPrintToStdOut("mysqldump  Ver 10.16 Distrib 10.1.24-MariaDB, for Linux (x86_64)")
+				fakeMysqlDump.WhenCalled().WillExitWith(1)
+				fakeMysqlDump.WhenCalledWith("-V").
+					WillPrintToStdOut("mysqldump  Ver 10.16 Distrib 10.1.24-MariaDB, for Linux (x86_64)")
+				fakeMysqlDump.WhenCalled().WillExitWith(0)
+			})
+
+			It("still dumps the other databases, and fails", func() {
+				Expect(session).Should(gexec.Exit(1))
+				Expect(fakeMysqlDump.Invocations()).To(HaveLen(4))
+				Expect(session.Err).To(gbytes.Say("1 of 2 databases failed"))
+				Expect(session.Err).To(gbytes.Say("ccdb: "))
+			})
+		})
+	})
+
+	Context("restore of several databases", func() {
+		var artifactDirectory string
+
+		BeforeEach(func() {
+			artifactDirectory, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			configFile = buildConfigFile(Config{
+				Adapter:  "mysql",
+				Username: username,
+				Password: password,
+				Host:     host,
+				Port:     port,
+				Databases: []DatabaseConfig{
+					{Name: "ccdb"},
+					{Name: "uaadb"},
+				},
+			})
+
+			Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, "ccdb.dump"), []byte("CCDB SQL"), 0600)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, "uaadb.dump"), []byte("UAADB SQL"), 0600)).To(Succeed())
+			writeChecksums(filepath.Join(artifactDirectory, "ccdb.dump"), filepath.Join(artifactDirectory, "uaadb.dump"))
+
+			envVars["MYSQL_CLIENT_PATH"] = fakeMysqlClient.Path
+			fakeMysqlClient.WhenCalled().WillExitWith(0)
+			fakeMysqlClient.WhenCalled().WillExitWith(0)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(artifactDirectory)
+		})
+
+		JustBeforeEach(func() {
+			cmd := exec.Command(
+				compiledSDKPath,
+				"--artifact-directory",
+				artifactDirectory,
+				"--config",
+				configFile.Name(),
+				"--restore")
+
+			for key, val := range envVars {
+				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
+			}
+
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit())
+		})
+
+		It("restores every database from its own file", func() {
+			Expect(session).Should(gexec.Exit(0))
+			Expect(fakeMysqlClient.Invocations()).To(HaveLen(2))
+
+			Expect(fakeMysqlClient.Invocations()[0].Args()).To(ContainElement("ccdb"))
+			Expect(fakeMysqlClient.Invocations()[0].Stdin()).To(ConsistOf("CCDB SQL"))
+
+			Expect(fakeMysqlClient.Invocations()[1].Args()).To(ContainElement("uaadb"))
+			Expect(fakeMysqlClient.Invocations()[1].Stdin()).To(ConsistOf("UAADB SQL"))
+		})
+
+		Context("when the file of one of the databases is missing", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(artifactDirectory, "ccdb.dump"))).To(Succeed())
+			})
+
+			It("still restores the other databases, and fails", func() {
+				Expect(session).Should(gexec.Exit(1))
+				Expect(fakeMysqlClient.Invocations()).To(HaveLen(1))
+				Expect(fakeMysqlClient.Invocations()[0].Args()).To(ContainElement("uaadb"))
+				Expect(session.Err).To(gbytes.Say("1 of 2 databases failed"))
+			})
+		})
+	})
+
+	Context("all databases", func() {
+		var artifactDirectory string
+
+		BeforeEach(func() {
+			artifactDirectory, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			configFile = buildConfigFile(Config{
+				Adapter:      "mysql",
+				Username:     username,
+				Password:     password,
+				Host:         host,
+				Port:         port,
+				AllDatabases: true,
+			})
+
+			envVars["MYSQL_DUMP_PATH"] = fakeMysqlDump.Path
+			envVars["MYSQL_CLIENT_PATH"] = fakeMysqlClient.Path
+
+			Expect(os.Mkdir(filepath.Join(artifactDirectory, "databases"), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, "databases", "ccdb.dump"), []byte("CCDB SQL"), 0600)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(artifactDirectory)
+		})
+
+		run := func(action string) {
+			cmd := exec.Command(
+				compiledSDKPath,
+				"--artifact-directory",
+				artifactDirectory,
+				"--config",
+				configFile.Name(),
+				action)
+
+			for key, val := range envVars {
+				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
+			}
+
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit())
+		}
+
+		Context("backup", func() {
+			BeforeEach(func() {
+				fakeMysqlClient.WhenCalled().WillPrintToStdOut("information_schema\nccdb\nmysql\nperformance_schema\nsys\n")
+				fakeMysqlClient.WhenCalled().WillPrintToStdOut("10.1.24-MariaDB-wsrep")
+				fakeMysqlDump.WhenCalledWith("-V").
+					WillPrintToStdOut("mysqldump  Ver 10.16 Distrib 10.1.24-MariaDB, for Linux (x86_64)")
+				fakeMysqlDump.WhenCalled().WillExitWith(0)
+			})
+
+			JustBeforeEach(func() {
+				run("--backup")
+			})
+
+			It("lists the databases", func() {
+				Expect(session).Should(gexec.Exit(0))
+				Expect(fakeMysqlClient.Invocations()[0].Args()).To(ConsistOf(
+					"--skip-column-names",
+					"--silent",
+					fmt.Sprintf("--user=%s", username),
+					fmt.Sprintf("--host=%s", host),
+					fmt.Sprintf("--port=%d", port),
+					"--execute=SHOW DATABASES",
+				))
+				Expect(fakeMysqlClient.Invocations()[0].Env()).To(HaveKeyWithValue("MYSQL_PWD", password))
+			})
+
+			It("backs up the databases, apart from the system databases", func() {
+				Expect(session).Should(gexec.Exit(0))
+				Expect(fakeMysqlDump.Invocations()).To(HaveLen(2))
+				Expect(fakeMysqlDump.Invocations()[1].Args()).To(ContainElement(
+					"--result-file=" + filepath.Join(artifactDirectory, "databases", "ccdb.dump")))
+
+				manifest, err := ioutil.ReadFile(filepath.Join(artifactDirectory, "databases.json"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(manifest).To(MatchJSON(`{"databases": ["ccdb"], "globals": false}`))
+			})
+		})
+
+		Context("restore", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, "databases.json"),
+					[]byte(`{"databases": ["ccdb"], "globals": false}`), 0600)).To(Succeed())
+				writeChecksums(filepath.Join(artifactDirectory, "databases", "ccdb.dump"))
+
+				fakeMysqlClient.WhenCalled().WillExitWith(0)
+				fakeMysqlClient.WhenCalled().WillExitWith(0)
+			})
+
+			JustBeforeEach(func() {
+				run("--restore")
+			})
+
+			It("creates the database if it doesn't exist, and restores it", func() {
+				Expect(session).Should(gexec.Exit(0))
+				Expect(fakeMysqlClient.Invocations()).To(HaveLen(2))
+				Expect(fakeMysqlClient.Invocations()[0].Args()).To(ContainElement("--execute=CREATE DATABASE IF NOT EXISTS `ccdb`"))
+				Expect(fakeMysqlClient.Invocations()[1].Args()).To(ContainElement("ccdb"))
+				Expect(fakeMysqlClient.Invocations()[1].Stdin()).To(ConsistOf("CCDB SQL"))
+			})
+		})
+	})
+
 	Context("restore", func() {
 		BeforeEach(func() {
 			configFile = buildConfigFile(Config{
@@ -261,19 +775,37 @@ var _ = Describe("MySQL", func() {
 
 		})
 
+		var artifactContents []byte
+		var writeChecksum bool
+		var extraArgs []string
+
+		BeforeEach(func() {
+			artifactContents = []byte("SOME BACKUP SQL")
+			writeChecksum = true
+			extraArgs = nil
+		})
+
+		AfterEach(func() {
+			os.Remove(artifactFile + ".sha256")
+		})
+
 		JustBeforeEach(func() {
-			err := ioutil.WriteFile(artifactFile, []byte("SOME BACKUP SQL"), 0644)
+			err := ioutil.WriteFile(artifactFile, artifactContents, 0644)
 			if err != nil {
 				log.Fatalln("Failed to write to artifact file, %s", err)
 			}
+			if writeChecksum {
+				writeChecksums(artifactFile)
+			}
 
 			cmd := exec.Command(
 				compiledSDKPath,
-				"--artifact-file",
-				artifactFile,
-				"--config",
-				configFile.Name(),
-				"--restore")
+				append([]string{
+					"--artifact-file",
+					artifactFile,
+					"--config",
+					configFile.Name(),
+					"--restore"}, extraArgs...)...)
 
 			for key, val := range envVars {
 				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
@@ -320,6 +852,109 @@ var _ = Describe("MySQL", func() {
 			})
 
 		})
+		Context("when the artifact is encrypted", func() {
+			BeforeEach(func() {
+				fakeMysqlClient.WhenCalled().WillExitWith(0)
+				envVars["MYSQL_CLIENT_PATH"] = fakeMysqlClient.Path
+
+				encrypted := bytes.NewBuffer(nil)
+				err := database.NewEncryptor("correct horse battery staple").Encrypt(encrypted, bytes.NewBufferString("SOME BACKUP SQL"))
+				Expect(err).NotTo(HaveOccurred())
+				artifactContents = encrypted.Bytes()
+			})
+
+			Context("and the encryption key is configured", func() {
+				BeforeEach(func() {
+					configFile = buildConfigFile(Config{
+						Adapter:       "mysql",
+						Username:      username,
+						Password:      password,
+						Host:          host,
+						Port:          port,
+						Database:      databaseName,
+						EncryptionKey: "correct horse battery staple",
+					})
+				})
+
+				It("restores the decrypted dump", func() {
+					Expect(session).Should(gexec.Exit(0))
+					Expect(fakeMysqlClient.Invocations()).To(HaveLen(1))
+					Expect(fakeMysqlClient.Invocations()[0].Stdin()).Should(ConsistOf("SOME BACKUP SQL"))
+				})
+			})
+
+			Context("and no encryption key is configured", func() {
+				It("fails without restoring", func() {
+					Expect(session).Should(gexec.Exit(1))
+					Expect(session.Err).To(gbytes.Say("artifact file is encrypted but no encryption_key is configured"))
+					Expect(fakeMysqlClient.Invocations()).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("when the artifact is compressed", func() {
+			BeforeEach(func() {
+				fakeMysqlClient.WhenCalled().WillExitWith(0)
+				envVars["MYSQL_CLIENT_PATH"] = fakeMysqlClient.Path
+
+				compressed := bytes.NewBuffer(nil)
+				gzipWriter := gzip.NewWriter(compressed)
+				gzipWriter.Write([]byte("SOME BACKUP SQL"))
+				gzipWriter.Close()
+				artifactContents = compressed.Bytes()
+			})
+
+			It("restores the decompressed dump", func() {
+				Expect(session).Should(gexec.Exit(0))
+				Expect(fakeMysqlClient.Invocations()).To(HaveLen(1))
+				Expect(fakeMysqlClient.Invocations()[0].Stdin()).Should(ConsistOf("SOME BACKUP SQL"))
+			})
+		})
+
+		Context("when the artifact does not match its checksum", func() {
+			BeforeEach(func() {
+				fakeMysqlClient.WhenCalled().WillExitWith(0)
+				envVars["MYSQL_CLIENT_PATH"] = fakeMysqlClient.Path
+
+				writeChecksum = false
+				err := ioutil.WriteFile(artifactFile+".sha256", []byte("not-the-checksum  artifact"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("fails without calling mysql", func() {
+				Expect(session).Should(gexec.Exit(1))
+				Expect(string(session.Err.Contents())).Should(ContainSubstring("checksum of '" + artifactFile + "' does not match"))
+				Expect(fakeMysqlClient.Invocations()).To(BeEmpty())
+			})
+		})
+
+		Context("when the artifact has no checksum", func() {
+			BeforeEach(func() {
+				fakeMysqlClient.WhenCalled().WillExitWith(0)
+				envVars["MYSQL_CLIENT_PATH"] = fakeMysqlClient.Path
+				writeChecksum = false
+			})
+
+			It("restores the dump with a warning", func() {
+				Expect(session).Should(gexec.Exit(0))
+				Expect(string(session.Err.Contents())).Should(ContainSubstring("no checksum file found for '" + artifactFile + "', skipping verification"))
+				Expect(fakeMysqlClient.Invocations()).To(HaveLen(1))
+				Expect(fakeMysqlClient.Invocations()[0].Stdin()).Should(ConsistOf("SOME BACKUP SQL"))
+			})
+
+			Context("and --require-checksum is provided", func() {
+				BeforeEach(func() {
+					extraArgs = []string{"--require-checksum"}
+				})
+
+				It("fails without calling mysql", func() {
+					Expect(session).Should(gexec.Exit(1))
+					Expect(string(session.Err.Contents())).Should(ContainSubstring("no checksum file found for '" + artifactFile + "'"))
+					Expect(fakeMysqlClient.Invocations()).To(BeEmpty())
+				})
+			})
+		})
+
 		Context("and mysql fails", func() {
 			BeforeEach(func() {
 				fakeMysqlClient.WhenCalled().WillExitWith(1)
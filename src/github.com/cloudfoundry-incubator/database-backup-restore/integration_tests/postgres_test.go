@@ -18,13 +18,17 @@ package integration_tests
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
+	binmock "github.com/pivotal-cf-experimental/go-binmock"
 )
 
 var _ = Describe("Postgres", func() {
@@ -51,6 +55,7 @@ var _ = Describe("Postgres", func() {
 		fakePgDump96.Reset()
 		fakePgRestore94.Reset()
 		fakePgRestore96.Reset()
+		fakePgDumpAll.Reset()
 
 	})
 
@@ -345,6 +350,117 @@ var _ = Describe("Postgres", func() {
 						Expect(session).Should(gexec.Exit(0))
 					})
 
+					Context("when 'tls' is specified in the configFile", func() {
+						BeforeEach(func() {
+							configFile = buildConfigFile(Config{
+								Adapter:  "postgres",
+								Username: username,
+								Password: password,
+								Host:     host,
+								Port:     port,
+								Database: databaseName,
+								TLS: &TLSConfig{
+									Cert: TLSCertConfig{
+										CA:          "A_CA_CERT",
+										Certificate: "A_CLIENT_CERT",
+										PrivateKey:  "A_CLIENT_KEY",
+									},
+								},
+							})
+						})
+
+						It("connects over TLS, verifying the server's certificate and host", func() {
+							Expect(session).Should(gexec.Exit(0))
+
+							for _, invocation := range []binmock.Invocation{
+								fakePgClient.Invocations()[0],
+								fakePgDump96.Invocations()[0],
+							} {
+								Expect(invocation.Env()).To(HaveKeyWithValue("PGSSLMODE", "verify-full"))
+								Expect(invocation.Env()).To(HaveKeyWithValue("PGSSLROOTCERT", HaveSuffix("ca.pem")))
+								Expect(invocation.Env()).To(HaveKeyWithValue("PGSSLCERT", HaveSuffix("certificate.pem")))
+								Expect(invocation.Env()).To(HaveKeyWithValue("PGSSLKEY", HaveSuffix("private_key.pem")))
+							}
+						})
+
+						It("removes the certificates when it is done", func() {
+							Expect(session).Should(gexec.Exit(0))
+
+							Expect(fakePgDump96.Invocations()[0].Env()["PGSSLROOTCERT"]).NotTo(BeAnExistingFile())
+						})
+
+						Context("and 'verify_mode' is 'ca'", func() {
+							BeforeEach(func() {
+								configFile = buildConfigFile(Config{
+									Adapter:  "postgres",
+									Username: username,
+									Password: password,
+									Host:     host,
+									Port:     port,
+									Database: databaseName,
+									TLS: &TLSConfig{
+										VerifyMode: "ca",
+										Cert:       TLSCertConfig{CA: "A_CA_CERT"},
+									},
+								})
+							})
+
+							It("only verifies the server's certificate", func() {
+								Expect(session).Should(gexec.Exit(0))
+
+								Expect(fakePgDump96.Invocations()[0].Env()).To(HaveKeyWithValue("PGSSLMODE", "verify-ca"))
+								Expect(fakePgDump96.Invocations()[0].Env()).NotTo(HaveKey("PGSSLCERT"))
+								Expect(fakePgDump96.Invocations()[0].Env()).NotTo(HaveKey("PGSSLKEY"))
+							})
+						})
+
+						Context("and 'verify_mode' is 'none'", func() {
+							BeforeEach(func() {
+								configFile = buildConfigFile(Config{
+									Adapter:  "postgres",
+									Username: username,
+									Password: password,
+									Host:     host,
+									Port:     port,
+									Database: databaseName,
+									TLS: &TLSConfig{
+										VerifyMode: "none",
+										Cert:       TLSCertConfig{CA: "A_CA_CERT"},
+									},
+								})
+							})
+
+							It("connects over TLS without verifying the server's certificate", func() {
+								Expect(session).Should(gexec.Exit(0))
+
+								Expect(fakePgDump96.Invocations()[0].Env()).To(HaveKeyWithValue("PGSSLMODE", "require"))
+								Expect(fakePgDump96.Invocations()[0].Env()).NotTo(HaveKey("PGSSLROOTCERT"))
+							})
+						})
+
+						Context("and no CA is configured", func() {
+							BeforeEach(func() {
+								configFile = buildConfigFile(Config{
+									Adapter:  "postgres",
+									Username: username,
+									Password: password,
+									Host:     host,
+									Port:     port,
+									Database: databaseName,
+									TLS:      &TLSConfig{},
+								})
+							})
+
+							It("verifies the server's certificate against the system's CAs", func() {
+								Expect(session).Should(gexec.Exit(0))
+
+								Expect(fakePgDump96.Invocations()[0].Env()).To(HaveKeyWithValue("PGSSLMODE", "verify-full"))
+								Expect(fakePgDump96.Invocations()[0].Env()).To(
+									HaveKeyWithValue("PGSSLROOTCERT", "/etc/ssl/certs/ca-certificates.crt"))
+							})
+						})
+					})
+
 					Context("when 'tables' are specified in the configFile", func() {
 						BeforeEach(func() {
 							configFile = buildConfigFile(Config{
@@ -454,8 +570,278 @@ var _ = Describe("Postgres", func() {
 					})
 				})
 			})
+
+			Context("when the version of the server cannot be checked over TLS", func() {
+				BeforeEach(func() {
+					configFile = buildConfigFile(Config{
+						Adapter:  "postgres",
+						Username: username,
+						Password: password,
+						Host:     host,
+						Port:     port,
+						Database: databaseName,
+						TLS: &TLSConfig{
+							Cert: TLSCertConfig{
+								CA:          "A_CA_CERT",
+								Certificate: "A_CLIENT_CERT",
+								PrivateKey:  "A_CLIENT_KEY",
+							},
+						},
+					})
+
+					envVars["PG_DUMP_9_6_PATH"] = fakePgDump96.Path
+					fakePgClient.WhenCalled().WillExitWith(1)
+				})
+
+				It("fails and removes the certificates", func() {
+					Expect(session).Should(gexec.Exit(1))
+					Expect(session.Err).To(gbytes.Say("Unable to check version of Postgres"))
+
+					Expect(fakePgClient.Invocations()[0].Env()["PGSSLKEY"]).NotTo(BeAnExistingFile())
+				})
+			})
+		})
+
+	})
+
+	Context("backup of all databases", func() {
+		var artifactDirectory string
+
+		BeforeEach(func() {
+			artifactDirectory, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			configFile = buildConfigFile(Config{
+				Adapter:      "postgres",
+				Username:     username,
+				Password:     password,
+				Host:         host,
+				Port:         port,
+				AllDatabases: true,
+			})
+
+			envVars["PG_CLIENT_PATH"] = fakePgClient.Path
+			envVars["PG_DUMP_9_6_PATH"] = fakePgDump96.Path
+			envVars["PG_DUMPALL_PATH"] = fakePgDumpAll.Path
+
+			fakePgClient.WhenCalled().WillPrintToStdOut(" ccdb\n uaadb\n\n")
+			for range []string{"ccdb", "uaadb"} {
+				fakePgClient.WhenCalled().WillPrintToStdOut(
+					" PostgreSQL 9.6.3 on x86_64-pc-linux-gnu, compiled by gcc " +
+						"(Ubuntu 4.8.4-2ubuntu1~14.04.3) 4.8.4, 64-bit")
+				fakePgDump96.WhenCalled().WillExitWith(0)
+			}
+			fakePgDumpAll.WhenCalled().WillExitWith(0)
+
+			// the fakes don't write the dumps
+			Expect(os.Mkdir(filepath.Join(artifactDirectory, "databases"), 0700)).To(Succeed())
+			for _, file := range []string{"globals.sql", "databases/ccdb.dump", "databases/uaadb.dump"} {
+				Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, file), []byte{}, 0600)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(artifactDirectory)
+		})
+
+		JustBeforeEach(func() {
+			cmd := exec.Command(
+				compiledSDKPath,
+				"--artifact-directory",
+				artifactDirectory,
+				"--config",
+				configFile.Name(),
+				"--backup")
+			for key, val := range envVars {
+				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
+			}
+
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit())
+		})
+
+		It("lists the databases, apart from the system databases", func() {
+			Expect(session).Should(gexec.Exit(0))
+			Expect(fakePgClient.Invocations()[0].Args()).To(ConsistOf(
+				"--tuples-only",
+				fmt.Sprintf("--username=%s", username),
+				fmt.Sprintf("--host=%s", host),
+				fmt.Sprintf("--port=%d", port),
+				"postgres",
+				"--command=SELECT datname FROM pg_database WHERE NOT datistemplate AND datname <> 'postgres';",
+			))
+		})
+
+		It("backs up the globals", func() {
+			Expect(session).Should(gexec.Exit(0))
+			Expect(fakePgDumpAll.Invocations()).To(HaveLen(1))
+			Expect(fakePgDumpAll.Invocations()[0].Args()).To(ConsistOf(
+				"--verbose",
+				"--globals-only",
+				fmt.Sprintf("--user=%s", username),
+				fmt.Sprintf("--host=%s", host),
+				fmt.Sprintf("--port=%d", port),
+				"--database=postgres",
+				"--file="+filepath.Join(artifactDirectory, "globals.sql"),
+			))
+			Expect(fakePgDumpAll.Invocations()[0].Env()).To(HaveKeyWithValue("PGPASSWORD", password))
+		})
+
+		It("backs up every database into its own file", func() {
+			Expect(session).Should(gexec.Exit(0))
+			Expect(fakePgDump96.Invocations()).To(HaveLen(2))
+			Expect(fakePgDump96.Invocations()[0].Args()).To(ContainElement("--file=" + filepath.Join(artifactDirectory, "databases", "ccdb.dump")))
+			Expect(fakePgDump96.Invocations()[0].Args()).To(ContainElement("ccdb"))
+			Expect(fakePgDump96.Invocations()[1].Args()).To(ContainElement("--file=" + filepath.Join(artifactDirectory, "databases", "uaadb.dump")))
+			Expect(fakePgDump96.Invocations()[1].Args()).To(ContainElement("uaadb"))
+		})
+
+		It("writes a manifest of the databases", func() {
+			Expect(session).Should(gexec.Exit(0))
+			manifest, err := ioutil.ReadFile(filepath.Join(artifactDirectory, "databases.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(manifest).To(MatchJSON(`{"databases": ["ccdb", "uaadb"], "globals": true}`))
+		})
+
+		Context("when PG_DUMPALL_PATH is not set", func() {
+			BeforeEach(func() {
+				delete(envVars, "PG_DUMPALL_PATH")
+			})
+
+			It("fails", func() {
+				Expect(session).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("PG_DUMPALL_PATH must be set to back up all databases"))
+			})
+		})
+	})
+
+	Context("restore of all databases", func() {
+		var artifactDirectory string
+		var globalsErrors string
+
+		BeforeEach(func() {
+			globalsErrors = ""
+			artifactDirectory, err = ioutil.TempDir("", "")
+			Expect(err).NotTo(HaveOccurred())
+
+			configFile = buildConfigFile(Config{
+				Adapter:      "postgres",
+				Username:     username,
+				Password:     password,
+				Host:         host,
+				Port:         port,
+				AllDatabases: true,
+			})
+
+			envVars["PG_CLIENT_PATH"] = fakePgClient.Path
+			envVars["PG_RESTORE_9_6_PATH"] = fakePgRestore96.Path
+
+			Expect(os.Mkdir(filepath.Join(artifactDirectory, "databases"), 0700)).To(Succeed())
+			for _, file := range []string{"globals.sql", "databases/ccdb.dump", "databases/uaadb.dump"} {
+				Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, file), []byte{}, 0600)).To(Succeed())
+				writeChecksums(filepath.Join(artifactDirectory, file))
+			}
+			Expect(ioutil.WriteFile(filepath.Join(artifactDirectory, "databases.json"),
+				[]byte(`{"databases": ["ccdb", "uaadb"], "globals": true}`), 0600)).To(Succeed())
 		})
 
+		AfterEach(func() {
+			os.RemoveAll(artifactDirectory)
+		})
+
+		JustBeforeEach(func() {
+			version := " PostgreSQL 9.6.3 on x86_64-pc-linux-gnu, compiled by gcc " +
+				"(Ubuntu 4.8.4-2ubuntu1~14.04.3) 4.8.4, 64-bit"
+
+			// the globals
+			fakePgClient.WhenCalled().WillPrintToStdErr(globalsErrors).WillExitWith(0)
+			// ccdb doesn't exist yet, so is created
+			fakePgClient.WhenCalled().WillPrintToStdOut(" uaadb\n")
+			fakePgClient.WhenCalled().WillExitWith(0)
+			fakePgClient.WhenCalled().WillPrintToStdOut(version)
+			// uaadb already exists
+			fakePgClient.WhenCalled().WillPrintToStdOut(" uaadb\n")
+			fakePgClient.WhenCalled().WillPrintToStdOut(version)
+
+			for range []string{"ccdb", "uaadb"} {
+				fakePgRestore96.WhenCalled().WillExitWith(0)
+				fakePgRestore96.WhenCalled().WillExitWith(0)
+			}
+		})
+
+		JustBeforeEach(func() {
+			cmd := exec.Command(
+				compiledSDKPath,
+				"--artifact-directory",
+				artifactDirectory,
+				"--config",
+				configFile.Name(),
+				"--restore")
+			for key, val := range envVars {
+				cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, val))
+			}
+
+			session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(session).Should(gexec.Exit())
+		})
+
+		It("restores the globals first", func() {
+			Expect(session).Should(gexec.Exit(0))
+			Expect(fakePgClient.Invocations()[0].Args()).To(ConsistOf(
+				fmt.Sprintf("--username=%s", username),
+				fmt.Sprintf("--host=%s", host),
+				fmt.Sprintf("--port=%d", port),
+				HavePrefix("--file="),
+				"postgres",
+			))
+		})
+
+		It("restores the globals from a filtered copy, which it cleans up", func() {
+			Expect(session).Should(gexec.Exit(0))
+			globalsArg := fakePgClient.Invocations()[0].Args()[3]
+			Expect(globalsArg).NotTo(Equal("--file=" + filepath.Join(artifactDirectory, "globals.sql")))
+			Expect(strings.TrimPrefix(globalsArg, "--file=")).NotTo(BeAnExistingFile())
+		})
+
+		Context("when psql reports that some of the globals already exist", func() {
+			BeforeEach(func() {
+				globalsErrors = `psql:/tmp/globals:5: ERROR:  role "cloud_controller" already exists` + "\n"
+			})
+
+			It("succeeds", func() {
+				Expect(session).Should(gexec.Exit(0))
+			})
+		})
+
+		Context("when psql fails to restore some of the globals", func() {
+			BeforeEach(func() {
+				globalsErrors = `psql:/tmp/globals:5: ERROR:  permission denied to create role` + "\n"
+			})
+
+			It("fails without restoring the databases", func() {
+				Expect(session).Should(gexec.Exit(1))
+				Expect(session.Err).To(gbytes.Say("could not restore globals: psql reported errors:"))
+				Expect(session.Err).To(gbytes.Say("permission denied to create role"))
+				Expect(fakePgRestore96.Invocations()).To(BeEmpty())
+			})
+		})
+
+		It("creates the databases that don't exist", func() {
+			Expect(session).Should(gexec.Exit(0))
+			Expect(fakePgClient.Invocations()).To(HaveLen(6))
+			Expect(fakePgClient.Invocations()[2].Args()).To(ContainElement(`--command=CREATE DATABASE "ccdb";`))
+		})
+
+		It("restores every database from its own file", func() {
+			Expect(session).Should(gexec.Exit(0))
+			Expect(fakePgRestore96.Invocations()).To(HaveLen(4))
+			Expect(fakePgRestore96.Invocations()[1].Args()).To(ContainElement("--dbname=ccdb"))
+			Expect(fakePgRestore96.Invocations()[1].Args()).To(ContainElement(filepath.Join(artifactDirectory, "databases", "ccdb.dump")))
+			Expect(fakePgRestore96.Invocations()[3].Args()).To(ContainElement("--dbname=uaadb"))
+			Expect(fakePgRestore96.Invocations()[3].Args()).To(ContainElement(filepath.Join(artifactDirectory, "databases", "uaadb.dump")))
+		})
 	})
 
 	Context("restore", func() {
@@ -469,7 +855,11 @@ var _ = Describe("Postgres", func() {
 				Port:     port,
 				Database: databaseName,
 			})
+			writeChecksums(artifactFile)
+		})
 
+		AfterEach(func() {
+			os.Remove(artifactFile + ".sha256")
 		})
 
 		JustBeforeEach(func() {
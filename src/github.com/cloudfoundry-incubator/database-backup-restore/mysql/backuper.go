@@ -27,9 +27,9 @@ func (b Backuper) Action(artifactFilePath string) error {
 		"--user=" + b.config.Username,
 		"--host=" + b.config.Host,
 		fmt.Sprintf("--port=%d", b.config.Port),
-		"--result-file=" + artifactFilePath,
-		b.config.Database,
 	}
+	cmdArgs = append(cmdArgs, tlsArgs(b.config)...)
+	cmdArgs = append(cmdArgs, "--result-file="+artifactFilePath, b.config.Database)
 
 	cmdArgs = append(cmdArgs, b.config.Tables...)
 
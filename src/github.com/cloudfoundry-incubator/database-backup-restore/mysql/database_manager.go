@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/config"
+	"github.com/cloudfoundry-incubator/database-backup-restore/runner"
+)
+
+var systemDatabases = map[string]bool{
+	"information_schema": true,
+	"performance_schema": true,
+	"mysql":              true,
+	"sys":                true,
+}
+
+type DatabaseManager struct {
+	config       config.ConnectionConfig
+	clientBinary string
+}
+
+func NewDatabaseManager(config config.ConnectionConfig, clientBinary string) DatabaseManager {
+	return DatabaseManager{config: config, clientBinary: clientBinary}
+}
+
+// ListDatabases leaves out the system databases.
+func (m DatabaseManager) ListDatabases() ([]string, error) {
+	stdout, _, err := m.runSQL("SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+
+	var databases []string
+	for _, database := range strings.Split(string(stdout), "\n") {
+		database = strings.TrimSpace(database)
+		if database != "" && !systemDatabases[database] {
+			databases = append(databases, database)
+		}
+	}
+	return databases, nil
+}
+
+// CreateDatabase does nothing if the database already exists.
+func (m DatabaseManager) CreateDatabase(name string) error {
+	_, _, err := m.runSQL(fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", strings.Replace(name, "`", "``", -1)))
+	return err
+}
+
+func (m DatabaseManager) runSQL(sql string) ([]byte, []byte, error) {
+	cmdArgs := []string{
+		"--skip-column-names",
+		"--silent",
+		"--user=" + m.config.Username,
+		"--host=" + m.config.Host,
+		fmt.Sprintf("--port=%d", m.config.Port),
+	}
+	cmdArgs = append(cmdArgs, tlsArgs(m.config)...)
+	cmdArgs = append(cmdArgs, "--execute="+sql)
+
+	return runner.Run(m.clientBinary, cmdArgs, map[string]string{"MYSQL_PWD": m.config.Password})
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"fmt"
 	"log"
 	"os/exec"
 	"regexp"
@@ -21,23 +22,26 @@ func (d DumpUtilityVersionDetector) GetVersion() (version.SemanticVersion, error
 	// /mysqldump\s+Ver\s+[^ ]+\s+Distrib\s+([^ ]+),/
 	clientCmd := exec.Command(d.mysqldumpPath, "-V")
 
-	semanticVersion := extractVersionUsingCommand(clientCmd, `^mysqldump\s+Ver\s+[^ ]+\s+Distrib\s+([^ ]+),`)
+	semanticVersion, err := extractVersionUsingCommand(clientCmd, `^mysqldump\s+Ver\s+[^ ]+\s+Distrib\s+([^ ]+),`)
+	if err != nil {
+		return version.SemanticVersion{}, err
+	}
 
 	log.Printf("Mysql dump version %v\n", semanticVersion)
 
 	return semanticVersion, nil
 }
 
-func extractVersionUsingCommand(cmd *exec.Cmd, pattern string) version.SemanticVersion {
+func extractVersionUsingCommand(cmd *exec.Cmd, pattern string) (version.SemanticVersion, error) {
 	stdout, err := cmd.Output()
 	if err != nil {
-		log.Fatalln("Error running command.", err)
+		return version.SemanticVersion{}, fmt.Errorf("Error running command: %s", err)
 	}
 
 	r := regexp.MustCompile(pattern)
 	matches := r.FindSubmatch(stdout)
 	if matches == nil {
-		log.Fatalln("Could not determine version by using search pattern:", pattern)
+		return version.SemanticVersion{}, fmt.Errorf("Could not determine version by using search pattern: %s", pattern)
 	}
 
 	versionString := matches[1]
@@ -45,7 +49,7 @@ func extractVersionUsingCommand(cmd *exec.Cmd, pattern string) version.SemanticV
 	r = regexp.MustCompile(`(\d+).(\d+).(\S+)`)
 	matches = r.FindSubmatch(versionString)
 	if matches == nil {
-		log.Fatalln("Could not determine version by using search pattern:", pattern)
+		return version.SemanticVersion{}, fmt.Errorf("Could not determine version by using search pattern: %s", pattern)
 	}
 
 	semanticVersion := version.SemanticVersion{
@@ -54,5 +58,5 @@ func extractVersionUsingCommand(cmd *exec.Cmd, pattern string) version.SemanticV
 		Patch: string(matches[3]),
 	}
 
-	return semanticVersion
+	return semanticVersion, nil
 }
@@ -3,7 +3,6 @@ package mysql
 import (
 	"bufio"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 
@@ -25,16 +24,21 @@ func NewRestorer(config config.ConnectionConfig, restoreBinary string) Restorer
 func (r Restorer) Action(artifactFilePath string) error {
 	artifactFile, err := os.Open(artifactFilePath)
 	if err != nil {
-		log.Fatalln("Error reading from artifact file,", err)
+		return fmt.Errorf("Error reading from artifact file: %s", err)
 	}
 
-	cmd := exec.Command(r.clientBinary,
+	cmdArgs := []string{
 		"-v",
-		"--user="+r.config.Username,
-		"--host="+r.config.Host,
+		"--user=" + r.config.Username,
+		"--host=" + r.config.Host,
 		fmt.Sprintf("--port=%d", r.config.Port),
-		r.config.Database,
-	)
+	}
+	cmdArgs = append(cmdArgs, tlsArgs(r.config)...)
+	cmdArgs = append(cmdArgs, r.config.Database)
+
+	cmd := exec.Command(r.clientBinary, cmdArgs...)
+
+	defer artifactFile.Close()
 
 	cmd.Stdin = bufio.NewReader(artifactFile)
 	cmd.Env = append(cmd.Env, "MYSQL_PWD="+r.config.Password)
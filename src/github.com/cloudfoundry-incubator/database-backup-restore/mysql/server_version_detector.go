@@ -19,16 +19,23 @@ func NewServerVersionDetector(mysqlPath string) ServerVersionDetector {
 }
 
 func (d ServerVersionDetector) GetVersion(config config.ConnectionConfig) (version.SemanticVersion, error) {
-	clientCmd := exec.Command(d.mysqlPath,
+	cmdArgs := []string{
 		"--skip-column-names",
 		"--silent",
 		fmt.Sprintf("--user=%s", config.Username),
 		fmt.Sprintf("--password=%s", config.Password),
 		fmt.Sprintf("--host=%s", config.Host),
 		fmt.Sprintf("--port=%d", config.Port),
-		"--execute=SELECT VERSION()")
+	}
+	cmdArgs = append(cmdArgs, tlsArgs(config)...)
+	cmdArgs = append(cmdArgs, "--execute=SELECT VERSION()")
 
-	semanticVersion := extractVersionUsingCommand(clientCmd, `(.+)`)
+	clientCmd := exec.Command(d.mysqlPath, cmdArgs...)
+
+	semanticVersion, err := extractVersionUsingCommand(clientCmd, `(.+)`)
+	if err != nil {
+		return version.SemanticVersion{}, err
+	}
 
 	log.Printf("MYSQL server version %v\n", semanticVersion)
 
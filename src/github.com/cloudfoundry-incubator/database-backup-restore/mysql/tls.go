@@ -0,0 +1,27 @@
+package mysql
+
+import "github.com/cloudfoundry-incubator/database-backup-restore/config"
+
+// tlsArgs are passed to every client that connects to the server. The MariaDB
+// client has no --ssl-mode, but verifies the server's certificate when it is
+// given a CA, and its host with --ssl-verify-server-cert.
+func tlsArgs(connectionConfig config.ConnectionConfig) []string {
+	if connectionConfig.TLS == nil {
+		return nil
+	}
+
+	args := []string{"--ssl"}
+	if connectionConfig.TLS.CAPath != "" {
+		args = append(args, "--ssl-ca="+connectionConfig.TLS.CAPath)
+	}
+	if connectionConfig.TLS.VerifyMode == config.TLSVerifyFull {
+		args = append(args, "--ssl-verify-server-cert")
+	}
+	if connectionConfig.TLS.CertificatePath != "" {
+		args = append(args,
+			"--ssl-cert="+connectionConfig.TLS.CertificatePath,
+			"--ssl-key="+connectionConfig.TLS.PrivateKeyPath)
+	}
+
+	return args
+}
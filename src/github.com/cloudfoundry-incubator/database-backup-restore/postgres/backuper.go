@@ -35,7 +35,7 @@ func (b Backuper) Action(artifactFilePath string) error {
 	_, _, err := runner.Run(
 		b.backupBinary,
 		cmdArgs,
-		map[string]string{"PGPASSWORD": b.config.Password},
+		connectionEnv(b.config),
 	)
 
 	return err
@@ -0,0 +1,29 @@
+package postgres
+
+import "github.com/cloudfoundry-incubator/database-backup-restore/config"
+
+var sslModes = map[string]string{
+	config.TLSVerifyNone: "require",
+	config.TLSVerifyCA:   "verify-ca",
+	config.TLSVerifyFull: "verify-full",
+}
+
+// connectionEnv configures every client that connects to the server, as
+// they all read the password and the TLS settings from the environment.
+func connectionEnv(config config.ConnectionConfig) map[string]string {
+	env := map[string]string{"PGPASSWORD": config.Password}
+
+	if config.TLS != nil {
+		env["PGSSLMODE"] = sslModes[config.TLS.VerifyMode]
+		if config.TLS.CAPath != "" {
+			env["PGSSLROOTCERT"] = config.TLS.CAPath
+		}
+
+		if config.TLS.CertificatePath != "" {
+			env["PGSSLCERT"] = config.TLS.CertificatePath
+			env["PGSSLKEY"] = config.TLS.PrivateKeyPath
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/config"
+	"github.com/cloudfoundry-incubator/database-backup-restore/runner"
+)
+
+// maintenanceDatabase is connected to for everything that isn't about a
+// particular database, as it exists on every server.
+const maintenanceDatabase = "postgres"
+
+type DatabaseManager struct {
+	config   config.ConnectionConfig
+	psqlPath string
+}
+
+func NewDatabaseManager(config config.ConnectionConfig, psqlPath string) DatabaseManager {
+	return DatabaseManager{config: config, psqlPath: psqlPath}
+}
+
+// ListDatabases leaves out the templates and the maintenance database.
+func (m DatabaseManager) ListDatabases() ([]string, error) {
+	stdout, _, err := m.runSQL(
+		fmt.Sprintf("SELECT datname FROM pg_database WHERE NOT datistemplate AND datname <> '%s';", maintenanceDatabase))
+	if err != nil {
+		return nil, err
+	}
+
+	var databases []string
+	for _, database := range parseTableList(string(stdout)) {
+		if database != "" {
+			databases = append(databases, database)
+		}
+	}
+	return databases, nil
+}
+
+// CreateDatabase does nothing if the database already exists.
+func (m DatabaseManager) CreateDatabase(name string) error {
+	databases, err := m.ListDatabases()
+	if err != nil {
+		return err
+	}
+
+	if NewTableSet(databases).Contains(name) {
+		return nil
+	}
+
+	_, _, err = m.runSQL(fmt.Sprintf(`CREATE DATABASE "%s";`, strings.Replace(name, `"`, `""`, -1)))
+	return err
+}
+
+func (m DatabaseManager) runSQL(sql string) ([]byte, []byte, error) {
+	return runner.Run(m.psqlPath,
+		[]string{"--tuples-only",
+			fmt.Sprintf("--username=%s", m.config.Username),
+			fmt.Sprintf("--host=%s", m.config.Host),
+			fmt.Sprintf("--port=%d", m.config.Port),
+			maintenanceDatabase,
+			"--command=" + sql,
+		}, connectionEnv(m.config))
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/cloudfoundry-incubator/database-backup-restore/config"
+	"github.com/cloudfoundry-incubator/database-backup-restore/runner"
+)
+
+// GlobalsBackuper dumps the roles and tablespaces, which belong to the server
+// rather than to any database, so pg_dump leaves them out.
+type GlobalsBackuper struct {
+	config        config.ConnectionConfig
+	pgDumpAllPath string
+}
+
+func NewGlobalsBackuper(config config.ConnectionConfig, pgDumpAllPath string) GlobalsBackuper {
+	return GlobalsBackuper{config: config, pgDumpAllPath: pgDumpAllPath}
+}
+
+func (b GlobalsBackuper) Action(artifactFilePath string) error {
+	_, _, err := runner.Run(b.pgDumpAllPath, []string{
+		"--verbose",
+		"--globals-only",
+		"--user=" + b.config.Username,
+		"--host=" + b.config.Host,
+		fmt.Sprintf("--port=%d", b.config.Port),
+		"--database=" + maintenanceDatabase,
+		"--file=" + artifactFilePath,
+	}, connectionEnv(b.config))
+
+	return err
+}
+
+// GlobalsRestorer runs the dumped globals with psql, leaving out the role it
+// connects as, so that the restore can't change its password or attributes.
+// psql carries on past the roles and tablespaces that already exist, but any
+// other error fails the restore.
+type GlobalsRestorer struct {
+	config   config.ConnectionConfig
+	psqlPath string
+}
+
+func NewGlobalsRestorer(config config.ConnectionConfig, psqlPath string) GlobalsRestorer {
+	return GlobalsRestorer{config: config, psqlPath: psqlPath}
+}
+
+func (r GlobalsRestorer) Action(artifactFilePath string) error {
+	globals, err := ioutil.ReadFile(artifactFilePath)
+	if err != nil {
+		return err
+	}
+
+	globalsFile, err := ioutil.TempFile("", "backup-restore-sdk")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(globalsFile.Name())
+
+	_, err = globalsFile.Write(GlobalsFilter(globals, r.config.Username))
+	closeErr := globalsFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	_, stderr, err := runner.Run(r.psqlPath, []string{
+		"--username=" + r.config.Username,
+		"--host=" + r.config.Host,
+		fmt.Sprintf("--port=%d", r.config.Port),
+		"--file=" + globalsFile.Name(),
+		maintenanceDatabase,
+	}, connectionEnv(r.config))
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, line := range strings.Split(string(stderr), "\n") {
+		if strings.Contains(line, "ERROR:") && !strings.HasSuffix(line, " already exists") {
+			failures = append(failures, line)
+		}
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("psql reported errors:\n%s", strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
+
+// GlobalsFilter removes the statements that create the role and set its
+// attributes from globals dumped by pg_dumpall.
+func GlobalsFilter(globals []byte, roleName string) []byte {
+	quotedRoleName := `"` + strings.Replace(roleName, `"`, `""`, -1) + `"`
+
+	outputLines := []string{}
+	for _, line := range strings.Split(string(globals), "\n") {
+		if isRoleStatement(line, roleName) || isRoleStatement(line, quotedRoleName) {
+			continue
+		}
+		outputLines = append(outputLines, line)
+	}
+	return []byte(strings.Join(outputLines, "\n"))
+}
+
+func isRoleStatement(line, roleName string) bool {
+	return line == "CREATE ROLE "+roleName+";" || strings.HasPrefix(line, "ALTER ROLE "+roleName+" WITH ")
+}
@@ -0,0 +1,43 @@
+package postgres_test
+
+import (
+	"github.com/cloudfoundry-incubator/database-backup-restore/postgres"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GlobalsFilter", func() {
+	It("removes the statements that create and alter the role", func() {
+		globals := []byte(`SET default_transaction_read_only = off;
+
+CREATE ROLE admin;
+ALTER ROLE admin WITH SUPERUSER INHERIT CREATEROLE CREATEDB LOGIN PASSWORD 'md5aaaa';
+CREATE ROLE cloud_controller;
+ALTER ROLE cloud_controller WITH NOSUPERUSER INHERIT LOGIN PASSWORD 'md5bbbb';
+CREATE ROLE admin_readonly;
+ALTER ROLE admin_readonly WITH NOSUPERUSER INHERIT LOGIN;
+
+GRANT admin_readonly TO cloud_controller GRANTED BY admin;
+`)
+
+		Expect(string(postgres.GlobalsFilter(globals, "admin"))).To(Equal(`SET default_transaction_read_only = off;
+
+CREATE ROLE cloud_controller;
+ALTER ROLE cloud_controller WITH NOSUPERUSER INHERIT LOGIN PASSWORD 'md5bbbb';
+CREATE ROLE admin_readonly;
+ALTER ROLE admin_readonly WITH NOSUPERUSER INHERIT LOGIN;
+
+GRANT admin_readonly TO cloud_controller GRANTED BY admin;
+`))
+	})
+
+	It("removes the statements when the role name is quoted", func() {
+		globals := []byte(`CREATE ROLE "Admin";
+ALTER ROLE "Admin" WITH SUPERUSER INHERIT LOGIN;
+CREATE ROLE cloud_controller;
+`)
+
+		Expect(string(postgres.GlobalsFilter(globals, "Admin"))).To(Equal(`CREATE ROLE cloud_controller;
+`))
+	})
+})
@@ -50,7 +50,7 @@ func (r Restorer) Action(artifactFilePath string) error {
 		"--clean",
 		fmt.Sprintf("--use-list=%s", listFile.Name()),
 		artifactFilePath},
-		map[string]string{"PGPASSWORD": r.config.Password})
+		connectionEnv(r.config))
 
 	return err
 }
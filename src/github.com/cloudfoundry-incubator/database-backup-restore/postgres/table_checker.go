@@ -26,7 +26,7 @@ func (c TableChecker) FindMissingTables(tableNames []string) ([]string, error) {
 			fmt.Sprintf("--port=%d", c.config.Port),
 			c.config.Database,
 			`--command=SELECT table_name FROM information_schema.tables WHERE table_type='BASE TABLE' AND table_schema='public';`,
-		}, map[string]string{"PGPASSWORD": c.config.Password})
+		}, connectionEnv(c.config))
 	if err != nil {
 		return nil, err
 	}
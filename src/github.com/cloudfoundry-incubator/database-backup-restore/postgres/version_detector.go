@@ -2,7 +2,6 @@ package postgres
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/cloudfoundry-incubator/database-backup-restore/config"
 	"github.com/cloudfoundry-incubator/database-backup-restore/runner"
@@ -24,10 +23,10 @@ func (d ServerVersionDetector) GetVersion(config config.ConnectionConfig) (versi
 		fmt.Sprintf("--port=%d", config.Port),
 		config.Database,
 		`--command=SELECT VERSION()`},
-		map[string]string{"PGPASSWORD": config.Password})
+		connectionEnv(config))
 
 	if err != nil {
-		log.Fatalf("Unable to check version of Postgres: %v\n%s\n%s", err, string(stdout), string(stderr))
+		return version.SemanticVersion{}, fmt.Errorf("Unable to check version of Postgres: %v\n%s\n%s", err, string(stdout), string(stderr))
 	}
 
 	return ParseVersion(string(stdout))
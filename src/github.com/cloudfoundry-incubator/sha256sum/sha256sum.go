@@ -0,0 +1,128 @@
+// Package sha256sum keeps the checksum of an artifact in a file next to it, in
+// the format of `sha256sum`, so that it can also be checked by hand with
+// `sha256sum -c`. It is shared by the database and blobstore backup tools.
+package sha256sum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func FilePath(filePath string) string {
+	return filePath + ".sha256"
+}
+
+// Write saves the checksum of the file, readable only by its owner.
+func Write(filePath string) error {
+	checksum, err := calculate(filePath)
+	if err != nil {
+		return err
+	}
+
+	contents := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(filePath))
+	err = WriteFileAtomically(FilePath(filePath), []byte(contents))
+	if err != nil {
+		return fmt.Errorf("could not write checksum file: %s", err.Error())
+	}
+
+	return nil
+}
+
+// Verify fails if the file does not match its checksum. A file without a
+// checksum, such as an artifact taken before checksums were introduced, is
+// only logged unless required is set.
+func Verify(filePath string, required bool) error {
+	contents, err := ioutil.ReadFile(FilePath(filePath))
+	if os.IsNotExist(err) {
+		if required {
+			return fmt.Errorf("no checksum file found for '%s'", filePath)
+		}
+		log.Printf("no checksum file found for '%s', skipping verification\n", filePath)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read checksum file: %s", err.Error())
+	}
+
+	fields := strings.Fields(string(contents))
+	if len(fields) == 0 {
+		return fmt.Errorf("checksum file '%s' is empty", FilePath(filePath))
+	}
+
+	checksum, err := calculate(filePath)
+	if err != nil {
+		return err
+	}
+
+	if fields[0] != checksum {
+		return fmt.Errorf("checksum of '%s' does not match: expected '%s', got '%s'", filePath, fields[0], checksum)
+	}
+
+	return nil
+}
+
+func calculate(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("could not read '%s': %s", filePath, err.Error())
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return "", fmt.Errorf("could not read '%s': %s", filePath, err.Error())
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// WriteFileAtomically writes to a temporary file in the same directory and
+// renames it into place, so that a crash never leaves a partially written file
+// at filePath. The file is only readable by its owner.
+func WriteFileAtomically(filePath string, contents []byte) error {
+	file, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(contents)
+	if err == nil {
+		err = file.Chmod(0600)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	err = os.Rename(file.Name(), filePath)
+	if err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(filePath))
+}
+
+func syncDir(dirPath string) error {
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
@@ -61,6 +61,8 @@ var _ = Describe("S3 backuper", func() {
 		fileName1 = uploadTimestampedFileToBucket(region, bucket, "file1", "FILE1")
 		fileName2 = uploadTimestampedFileToBucket(region, bucket, "file2", "FILE2")
 
+		backuperInstance.runOnVMAndSucceed("/var/vcap/jobs/aws-s3-versioned-blobstore-backup-restorer/bin/bbr/pre-backup-lock")
+
 		backuperInstance.runOnVMAndSucceed("BBR_ARTIFACT_DIRECTORY=" + artifactDirPath +
 			" /var/vcap/jobs/aws-s3-versioned-blobstore-backup-restorer/bin/bbr/backup")
 